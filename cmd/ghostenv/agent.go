@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/SrPlugin/GhostEnv/internal/agent"
+	"github.com/SrPlugin/GhostEnv/internal/audit"
+	"github.com/SrPlugin/GhostEnv/internal/cipher"
+	"github.com/SrPlugin/GhostEnv/internal/storage"
+	"github.com/SrPlugin/GhostEnv/internal/vault"
+)
+
+func agentSocketPath(flagValue string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+	if sock := os.Getenv(agent.SocketEnv); sock != "" {
+		return sock
+	}
+	return agent.DefaultSocketPath()
+}
+
+// agentUnlocked reports whether the agent holds the keys for every vault the
+// command will decrypt, so the password prompt can be skipped.
+func agentUnlocked(environment string) bool {
+	if os.Getenv(agent.SocketEnv) == "" {
+		return false
+	}
+	vaultPath, _, err := vault.GetVaultPath(environment)
+	if err != nil {
+		return false
+	}
+	paths := []string{vaultPath}
+	if shared := vault.SharedVaultPath(); shared != "" && storage.VaultExists(shared) {
+		paths = append(paths, shared)
+	}
+	for _, p := range paths {
+		data, err := storage.LoadVault(p)
+		if err != nil {
+			return false
+		}
+		salt, err := cipher.Salt(data)
+		if err != nil || !cipher.CachedKey(salt) {
+			return false
+		}
+	}
+	return true
+}
+
+func (h *handlers) handleAgentStart(socketFlag string, ttl time.Duration, foreground bool) (err error) {
+	socket := agentSocketPath(socketFlag)
+	defer func() { auditLog(audit.ActionAgentStart, "", "", "", err) }()
+	if foreground {
+		return agent.Serve(socket, ttl)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate ghostenv binary: %w", err)
+	}
+	args := []string{"agent", "start", "--foreground", "--socket", socket, "--ttl", ttl.String()}
+	if err = agent.Spawn(exe, args); err != nil {
+		return fmt.Errorf("failed to start agent: %w", err)
+	}
+
+	client := agent.NewClient(socket)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, statusErr := client.Status(); statusErr == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("agent did not start listening on %s", socket)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	fmt.Printf("export %s=%s\n", agent.SocketEnv, socket)
+	fmt.Fprintf(os.Stderr, "Agent started. Run: eval \"$(ghostenv agent start)\" to set %s in your shell.\n", agent.SocketEnv)
+	return nil
+}
+
+func (h *handlers) handleAgentLock(socketFlag string) (err error) {
+	defer func() { auditLog(audit.ActionAgentLock, "", "", "", err) }()
+	if err = agent.NewClient(agentSocketPath(socketFlag)).Lock(); err != nil {
+		return err
+	}
+	fmt.Println("Agent locked: all cached keys wiped")
+	return nil
+}
+
+func (h *handlers) handleAgentStop(socketFlag string) (err error) {
+	defer func() { auditLog(audit.ActionAgentStop, "", "", "", err) }()
+	if err = agent.NewClient(agentSocketPath(socketFlag)).Stop(); err != nil {
+		return err
+	}
+	fmt.Println("Agent stopped")
+	return nil
+}
+
+func (h *handlers) handleAgentStatus(socketFlag string) error {
+	socket := agentSocketPath(socketFlag)
+	st, err := agent.NewClient(socket).Status()
+	if err != nil {
+		return err
+	}
+	fmt.Println("Agent Status")
+	fmt.Println("------------")
+	fmt.Printf("Socket:      %s\n", socket)
+	fmt.Printf("PID:         %d\n", st.Pid)
+	fmt.Printf("Keys:        %d\n", st.Keys)
+	if st.TTL > 0 {
+		fmt.Printf("Idle TTL:    %s\n", st.TTL)
+	} else {
+		fmt.Println("Idle TTL:    none")
+	}
+	return nil
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/SrPlugin/GhostEnv/internal/audit"
+	"github.com/SrPlugin/GhostEnv/internal/config"
+	"github.com/SrPlugin/GhostEnv/internal/keyfilter"
+	"github.com/SrPlugin/GhostEnv/internal/vault"
+)
+
+type auditQuery struct {
+	File        string
+	Actions     string
+	Keys        string
+	Environment string
+	User        string
+	Since       string
+	Until       string
+	Success     *bool
+}
+
+// filter turns the flags into an audit.Filter. Times are RFC 3339, a date, or
+// an age such as 24h or 7d meaning that long ago.
+func (q auditQuery) filter() (audit.Filter, error) {
+	f := audit.Filter{
+		Actions:     keyfilter.Split(q.Actions),
+		Keys:        keyfilter.Split(q.Keys),
+		Environment: q.Environment,
+		User:        q.User,
+		Success:     q.Success,
+	}
+	var err error
+	if f.Since, err = parseTimeArg(q.Since); err != nil {
+		return f, fmt.Errorf("invalid --since: %w", err)
+	}
+	if f.Until, err = parseTimeArg(q.Until); err != nil {
+		return f, fmt.Errorf("invalid --until: %w", err)
+	}
+	return f, nil
+}
+
+func parseTimeArg(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	d, err := config.ParseAge(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not a time, date or age", s)
+	}
+	return time.Now().Add(-d), nil
+}
+
+func (q auditQuery) entries(environment string) ([]audit.Entry, error) {
+	f, err := q.filter()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := audit.ReadEntries(auditLogPath(q.File, environment))
+	if err != nil {
+		return nil, err
+	}
+	return f.Apply(entries), nil
+}
+
+// auditLogPath is the log the audit commands read: --file, else the file sink
+// for environment's vault.
+func auditLogPath(file, environment string) string {
+	if file != "" {
+		return file
+	}
+	vaultPath, _, _ := vault.GetVaultPath(environment)
+	return audit.LogPath(vaultPath)
+}
+
+func (h *handlers) handleAuditVerify(file, environment string) error {
+	path := auditLogPath(file, environment)
+	key, err := audit.Key(false)
+	if err != nil {
+		return err
+	}
+	r, err := audit.Verify(path, key)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Verified %d entries in %d files (%s)\n", r.Entries, len(r.Files), path)
+	if r.LastSeq > 0 {
+		fmt.Printf("Chain: seq %d to %d\n", r.FirstSeq, r.LastSeq)
+	}
+	if r.Unsigned > 0 {
+		fmt.Printf("Note: %d entries written before signing was enabled are not covered\n", r.Unsigned)
+	}
+	for _, n := range r.Notes {
+		fmt.Printf("Note: %s\n", n)
+	}
+	for _, p := range r.Problems {
+		fmt.Printf("  %s\n", p)
+	}
+	if !r.OK() {
+		return fmt.Errorf("audit log failed verification: %d problems", len(r.Problems))
+	}
+	fmt.Println("OK: no gaps, reordering or edits found")
+	return nil
+}
+
+func (h *handlers) handleAuditShow(q auditQuery, outFormat, environment string) error {
+	entries, err := q.entries(environment)
+	if err != nil {
+		return err
+	}
+	switch outFormat {
+	case "", "table":
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TIME\tUSER\tACTION\tENV\tKEY\tSTATUS")
+		for _, e := range entries {
+			status := "ok"
+			if !e.Success {
+				status = "FAILED: " + e.Error
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", e.Time().Local().Format("2006-01-02 15:04:05"),
+				dash(e.User), e.Action, dash(e.Environment), dash(e.Key), status)
+		}
+		return w.Flush()
+	case "json":
+		if entries == nil {
+			entries = []audit.Entry{}
+		}
+		data, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	case "csv":
+		w := csv.NewWriter(os.Stdout)
+		_ = w.Write([]string{"seq", "timestamp", "level", "user", "host", "pid", "ppid", "version", "action",
+			"environment", "vault_path", "key", "command", "client", "correlation_id", "success", "error", "transaction"})
+		for _, e := range entries {
+			_ = w.Write([]string{strconv.FormatUint(e.Seq, 10), e.Timestamp, e.Level, e.User, e.Host,
+				strconv.Itoa(e.PID), strconv.Itoa(e.PPID), e.Version, e.Action, e.Environment, e.VaultPath, e.Key,
+				strings.Join(e.Command, " "), e.Client, e.CorrelationID, strconv.FormatBool(e.Success), e.Error, e.Transaction})
+		}
+		w.Flush()
+		return w.Error()
+	}
+	return fmt.Errorf("unknown format %q (use table, json or csv)", outFormat)
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func (h *handlers) handleAuditSummary(q auditQuery, window time.Duration, threshold int, environment string) error {
+	entries, err := q.entries(environment)
+	if err != nil {
+		return err
+	}
+	s := audit.Summarize(entries, window, threshold)
+	if s.Entries == 0 {
+		fmt.Println("No matching audit entries")
+		return nil
+	}
+	const stamp = "2006-01-02 15:04:05"
+	fmt.Printf("%d entries from %s to %s\n", s.Entries, s.First.Local().Format(stamp), s.Last.Local().Format(stamp))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\nBy action\tTOTAL\tFAILED")
+	for _, c := range s.ByAction {
+		fmt.Fprintf(w, "  %s\t%d\t%d\n", c.Name, c.Total, c.Failed)
+	}
+	fmt.Fprintln(w, "\nBy user\tTOTAL\tFAILED")
+	for _, c := range s.ByUser {
+		fmt.Fprintf(w, "  %s\t%d\t%d\n", c.Name, c.Total, c.Failed)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("\nFailed authentication: %d\n", s.AuthFailures)
+	for _, sp := range s.Spikes {
+		fmt.Printf("  spike: %d failures between %s and %s\n", sp.Failures, sp.Start.Local().Format(stamp), sp.End.Local().Format(stamp))
+	}
+
+	if len(s.LastAccess) > 0 {
+		fmt.Println("\nLast access per key")
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, a := range s.LastAccess {
+			e := a.Entry
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\n", a.Key, e.Time().Local().Format(stamp), e.Action, dash(e.User), dash(e.Environment))
+		}
+		return w.Flush()
+	}
+	return nil
+}
+
+// handleAuditUnmask maps masked key hashes (audit.mask_keys) back to names by
+// hashing the keys of the environment's vault and the shared vault. Without
+// hashes it lists every masked key found in the log.
+func (h *handlers) handleAuditUnmask(password []byte, hashes []string, q auditQuery, environment string) (err error) {
+	defer zeroBytes(password)
+	vaultPath, _, _ := vault.GetVaultPath(environment)
+	rec := newAuditRecord(audit.ActionAuditUnmask, vaultPath, environment, "")
+	defer func() { rec.done(err) }()
+	entries, err := q.entries(environment)
+	if err != nil {
+		return err
+	}
+	vaultService, err := h.getVaultService(environment)
+	if err != nil {
+		return fmt.Errorf("failed to resolve vault: %w", err)
+	}
+	secrets, err := loadRunSecrets(vaultService, password)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(secrets))
+	for k := range secrets {
+		names = append(names, k)
+	}
+	u := audit.NewUnmasker(names)
+
+	type match struct {
+		name  string
+		count int
+	}
+	found := make(map[string]*match)
+	var order []string
+	for _, hash := range hashes {
+		if !audit.IsMasked(hash) {
+			return fmt.Errorf("%q is not a masked key (expected hmac:...)", hash)
+		}
+		if found[hash] == nil {
+			found[hash] = &match{}
+			order = append(order, hash)
+		}
+	}
+	for _, e := range entries {
+		if !audit.IsMasked(e.Key) {
+			continue
+		}
+		m := found[e.Key]
+		if m == nil {
+			if len(hashes) > 0 {
+				continue
+			}
+			m = &match{}
+			found[e.Key] = m
+			order = append(order, e.Key)
+		}
+		m.count++
+		if m.name == "" {
+			m.name, _ = u.Key(e.Key)
+		}
+	}
+	if len(order) == 0 {
+		fmt.Println("No masked keys in the audit log")
+		return nil
+	}
+
+	if err = rec.commit(); err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "HASH\tKEY\tENTRIES")
+	for _, hash := range order {
+		m := found[hash]
+		name := m.name
+		if name == "" {
+			name = "? (not in this vault)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\n", hash, name, m.count)
+	}
+	return w.Flush()
+}
+
+// successFlag reads --success only when it was given, so leaving it out
+// matches both outcomes.
+func successFlag(set bool, value bool) *bool {
+	if !set {
+		return nil
+	}
+	return &value
+}
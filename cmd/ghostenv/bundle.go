@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/SrPlugin/GhostEnv/internal/bundle"
+	"github.com/SrPlugin/GhostEnv/internal/config"
+	"github.com/SrPlugin/GhostEnv/internal/dotenv"
+	"github.com/SrPlugin/GhostEnv/internal/generate"
+	"golang.org/x/term"
+)
+
+const bundlePassphraseEnv = "GHOSTENV_BUNDLE_PASSPHRASE"
+
+func defaultIdentityPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, config.ProjectVaultDir, bundle.IdentityFileName), nil
+}
+
+// resolveRecipient accepts a recipient string or a file containing one.
+func resolveRecipient(s string) (string, error) {
+	if strings.HasPrefix(s, "ghostenv-") {
+		return s, nil
+	}
+	data, err := os.ReadFile(s)
+	if err != nil {
+		return "", fmt.Errorf("recipient must be a ghostenv-x25519:... key or a file containing one: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// sealBundle encrypts secrets for --encrypt. Without a recipient it generates
+// a one-time passphrase, which is returned for the caller to show once.
+func sealBundle(secrets map[string]string, environment string, opts exportOptions) ([]byte, string, error) {
+	c := config.Current()
+	if environment == "" {
+		environment = c.Project.DefaultEnv
+	}
+	meta := bundle.Meta{Environment: environment, Project: c.Project.Name}
+	if opts.Expires > 0 {
+		meta.ExpiresAt = time.Now().Add(opts.Expires)
+	}
+	if opts.Recipient != "" {
+		recipient, err := resolveRecipient(opts.Recipient)
+		if err != nil {
+			return nil, "", err
+		}
+		out, err := bundle.SealForRecipient(secrets, meta, recipient)
+		return out, "", err
+	}
+	passphrase, err := generate.Generate(generate.Options{Type: generate.TypePassphrase})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate passphrase: %w", err)
+	}
+	out, err := bundle.SealWithPassphrase(secrets, meta, []byte(passphrase))
+	return out, passphrase, err
+}
+
+// openBundle decrypts a bundle for import --bundle and returns its secrets as
+// entries in key order.
+func openBundle(data []byte, identityPath string) ([]dotenv.Entry, error) {
+	b, err := bundle.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	var secrets map[string]string
+	switch b.Header.Mode {
+	case bundle.ModePassphrase:
+		passphrase, err := bundlePassphrase()
+		if err != nil {
+			return nil, err
+		}
+		defer zeroBytes(passphrase)
+		secrets, err = b.Open(passphrase, nil)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		if identityPath == "" {
+			if identityPath, err = defaultIdentityPath(); err != nil {
+				return nil, err
+			}
+		}
+		identity, err := bundle.LoadIdentity(identityPath)
+		if err != nil {
+			return nil, err
+		}
+		if secrets, err = b.Open(nil, identity); err != nil {
+			return nil, err
+		}
+	}
+	entries := make([]dotenv.Entry, 0, len(secrets))
+	for _, k := range b.Header.Keys {
+		entries = append(entries, dotenv.Entry{Key: k, Value: secrets[k]})
+	}
+	return entries, nil
+}
+
+func bundlePassphrase() ([]byte, error) {
+	if p := os.Getenv(bundlePassphraseEnv); p != "" {
+		return []byte(p), nil
+	}
+	fmt.Fprint(os.Stderr, "Enter bundle passphrase: ")
+	p, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	if len(p) == 0 {
+		return nil, fmt.Errorf("passphrase cannot be empty")
+	}
+	return p, nil
+}
+
+func (h *handlers) handleBundleKeygen(output string) error {
+	if output == "" {
+		var err error
+		if output, err = defaultIdentityPath(); err != nil {
+			return err
+		}
+	}
+	recipient, err := bundle.GenerateIdentity(output)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Identity written to %s (keep it private)\n", output)
+	fmt.Fprintln(os.Stderr, "Share this public key with people who send you bundles:")
+	fmt.Println(recipient)
+	return nil
+}
+
+func (h *handlers) handleBundleInspect(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read bundle: %w", err)
+	}
+	b, err := bundle.Parse(data)
+	if err != nil {
+		return err
+	}
+	hd := b.Header
+	fmt.Printf("Mode:        %s\n", hd.Mode)
+	if hd.Recipient != "" {
+		fmt.Printf("Recipient:   %s\n", hd.Recipient)
+	}
+	fmt.Printf("Created:     %s\n", hd.CreatedAt.Local().Format(time.RFC3339))
+	switch {
+	case hd.ExpiresAt == nil:
+		fmt.Printf("Expires:     never\n")
+	case b.Expired(time.Now()):
+		fmt.Printf("Expires:     %s (expired)\n", hd.ExpiresAt.Local().Format(time.RFC3339))
+	default:
+		fmt.Printf("Expires:     %s\n", hd.ExpiresAt.Local().Format(time.RFC3339))
+	}
+	if hd.Project != "" {
+		fmt.Printf("Project:     %s\n", hd.Project)
+	}
+	if hd.Environment != "" {
+		fmt.Printf("Environment: %s\n", hd.Environment)
+	}
+	fmt.Printf("Keys (%d):\n", len(hd.Keys))
+	for _, k := range hd.Keys {
+		fmt.Printf("  %s\n", k)
+	}
+	return nil
+}
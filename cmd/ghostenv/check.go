@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/SrPlugin/GhostEnv/internal/audit"
+	"github.com/SrPlugin/GhostEnv/internal/config"
+	"github.com/SrPlugin/GhostEnv/internal/validator"
+	"github.com/SrPlugin/GhostEnv/internal/vault"
+)
+
+// schemaRules returns the keys declared for env under schema in the config.
+// Call it after a vault path lookup has loaded the config.
+func schemaRules(env string) map[string]validator.Rule {
+	rules := make(map[string]validator.Rule)
+	c := config.Current()
+	if c == nil {
+		return rules
+	}
+	for k, r := range c.Schema.For(env) {
+		rules[k] = validator.Rule(r)
+	}
+	return rules
+}
+
+func printProblems(w io.Writer, problems []validator.Problem) {
+	for _, p := range problems {
+		fmt.Fprintf(w, "  %s\n", p)
+	}
+}
+
+// handleCheck validates the secrets run would inject for environment against
+// the schema declared for it.
+func (h *handlers) handleCheck(password []byte, environment string) (err error) {
+	defer zeroBytes(password)
+	vaultPath, _, _ := vault.GetVaultPath(environment)
+	env := resolveEnvName(environment)
+	defer func() { auditLog(audit.ActionCheck, vaultPath, env, "", err) }()
+
+	rules := schemaRules(env)
+	if len(rules) == 0 {
+		return fmt.Errorf("no schema declared for %s (add schema.%s or schema.\"*\" to .ghostenv.yml)", env, env)
+	}
+	vaultService, err := h.getVaultService(environment)
+	if err != nil {
+		return fmt.Errorf("failed to resolve vault: %w", err)
+	}
+	secrets, err := loadRunSecrets(vaultService, password)
+	if err != nil {
+		return err
+	}
+
+	problems := validator.Check(secrets, rules)
+	var undeclared []string
+	for k := range secrets {
+		if _, ok := rules[k]; !ok {
+			undeclared = append(undeclared, k)
+		}
+	}
+	sort.Strings(undeclared)
+
+	fmt.Printf("Checked %s against %d declared keys\n", env, len(rules))
+	for _, k := range undeclared {
+		fmt.Printf("  %s: not declared in the schema\n", k)
+	}
+	if len(problems) > 0 {
+		printProblems(os.Stdout, problems)
+		return fmt.Errorf("%s does not match its schema: %d problems", env, len(problems))
+	}
+	fmt.Printf("OK: all %d declared keys are valid\n", len(rules))
+	return nil
+}
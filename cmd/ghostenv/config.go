@@ -0,0 +1,195 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/SrPlugin/GhostEnv/internal/config"
+	"github.com/SrPlugin/GhostEnv/internal/vault"
+	"gopkg.in/yaml.v3"
+)
+
+// loadConfig reads the config for the project around the working directory,
+// as every other command does, but returns the error instead of stopping at
+// the first vault lookup.
+func loadConfig() (*config.Config, error) {
+	return config.Load(vault.FindProjectRoot())
+}
+
+// handleConfigValidate checks the global config with every project of the
+// project file.
+func (h *handlers) handleConfigValidate() error {
+	root := vault.FindProjectRoot()
+	projects, err := config.Projects(root)
+	if err != nil {
+		return reportConfigProblems(err)
+	}
+	if len(projects) < 2 {
+		cfg, err := config.Load(root)
+		if err != nil {
+			return reportConfigProblems(err)
+		}
+		files := cfg.Files()
+		if len(files) == 0 {
+			fmt.Println("No config files found; the defaults are valid")
+			return nil
+		}
+		for _, f := range files {
+			fmt.Printf("OK: %s\n", shortPath(f))
+		}
+		return nil
+	}
+
+	var problems []config.Problem
+	for _, p := range projects {
+		_, err := config.LoadProject(root, p.Name)
+		var ve *config.ValidationError
+		switch {
+		case errors.As(err, &ve):
+			problems = append(problems, ve.Problems...)
+		case err != nil:
+			problems = append(problems, config.Problem{Msg: fmt.Sprintf("project %s: %v", p.Name, err)})
+		default:
+			fmt.Printf("OK: %s (project %s)\n", shortPath(p.File), p.Name)
+		}
+	}
+	if len(problems) > 0 {
+		return reportConfigProblems(&config.ValidationError{Problems: problems})
+	}
+	return nil
+}
+
+func reportConfigProblems(err error) error {
+	var ve *config.ValidationError
+	if !errors.As(err, &ve) {
+		return err
+	}
+	for _, p := range ve.Problems {
+		fmt.Println(p)
+	}
+	if len(ve.Problems) == 1 {
+		return fmt.Errorf("config is invalid")
+	}
+	return fmt.Errorf("config is invalid: %d problems", len(ve.Problems))
+}
+
+// handleListProjects lists the projects of a multi-project .ghostenv.yml,
+// marking the one commands run in here would use.
+func (h *handlers) handleListProjects() error {
+	root := vault.FindProjectRoot()
+	projects, err := config.Projects(root)
+	if err != nil {
+		return err
+	}
+	if len(projects) == 0 {
+		fmt.Printf("No %s found\n", config.ProjectConfigName)
+		return nil
+	}
+	current := ""
+	if cfg, err := config.Load(root); err == nil {
+		current = cfg.Project.Name
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  NAME\tDIRECTORY\tVERSION")
+	for _, p := range projects {
+		mark := " "
+		if p.Name == current {
+			mark = "*"
+		}
+		fmt.Fprintf(w, "%s %s\t%s\t%s\n", mark, dash(p.Name), shortPath(p.Dir), dash(p.Version))
+	}
+	return w.Flush()
+}
+
+// handleConfigShow prints the merged config with a comment on each value
+// saying which file and line set it, or that it is a default.
+func (h *handlers) handleConfigShow() error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	shown := *cfg
+	if shown.Audit.Enabled == nil {
+		enabled := shown.Audit.IsEnabled()
+		shown.Audit.Enabled = &enabled
+	}
+	if shown.Storage.RecursiveSearch == nil {
+		recursive := shown.Storage.IsRecursive()
+		shown.Storage.RecursiveSearch = &recursive
+	}
+	var doc yaml.Node
+	if err := doc.Encode(&shown); err != nil {
+		return err
+	}
+	annotateSources(&doc, cfg, "")
+	out, err := yaml.Marshal(&doc)
+	if err != nil {
+		return err
+	}
+
+	files := make([]string, len(cfg.Files()))
+	for i, f := range cfg.Files() {
+		files[i] = shortPath(f)
+	}
+	if len(files) == 0 {
+		files = []string{"none, all defaults"}
+	}
+	if name := cfg.Project.Name; name != "" && len(cfg.Files()) > 0 {
+		fmt.Printf("# Project %s in %s\n", name, shortPath(cfg.ProjectDir()))
+	}
+	fmt.Printf("# Effective configuration (files: %s)\n", strings.Join(files, ", "))
+	fmt.Print(string(out))
+	return nil
+}
+
+func annotateSources(n *yaml.Node, cfg *config.Config, prefix string) {
+	if n.Kind == yaml.DocumentNode && len(n.Content) > 0 {
+		n = n.Content[0]
+	}
+	if n.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, value := n.Content[i], n.Content[i+1]
+		path := key.Value
+		if prefix != "" {
+			path = prefix + "." + key.Value
+		}
+		switch value.Kind {
+		case yaml.MappingNode:
+			annotateSources(value, cfg, path)
+		case yaml.ScalarNode:
+			value.LineComment = sourceComment(cfg, path)
+		default:
+			key.LineComment = sourceComment(cfg, path)
+		}
+	}
+}
+
+func sourceComment(cfg *config.Config, path string) string {
+	o, ok := cfg.Source(path)
+	if !ok {
+		return "default"
+	}
+	return fmt.Sprintf("%s:%d", shortPath(o.File), o.Line)
+}
+
+// shortPath shows path relative to the working directory when it is below
+// it, else with the home directory as ~.
+func shortPath(path string) string {
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		if rel, err := filepath.Rel(home, path); err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.Join("~", rel)
+		}
+	}
+	return path
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/SrPlugin/GhostEnv/internal/audit"
+	"github.com/SrPlugin/GhostEnv/internal/diff"
+	"github.com/SrPlugin/GhostEnv/internal/keyfilter"
+	"github.com/SrPlugin/GhostEnv/internal/vault"
+	"golang.org/x/term"
+)
+
+type copyOptions struct {
+	From          string
+	To            string
+	Pattern       string
+	Overwrite     bool
+	DryRun        bool
+	AllowReserved bool
+	FromPass      string
+	ToPass        string
+}
+
+type promoteOptions struct {
+	From          string
+	To            string
+	Pattern       string
+	Prune         bool
+	Yes           bool
+	Out           string
+	Plan          string
+	AllowReserved bool
+	FromPass      string
+	ToPass        string
+}
+
+// applyChanges writes changes (computed as diff.Compare(dst, src)) into dst.
+// Overwritten values go to the key's history; encodings follow the value.
+// Pruned keys are dropped together with their history.
+func applyChanges(dst, src *envVault, changes []diff.Change, overwrite, prune bool) (applied, skipped []diff.Change) {
+	meta, srcMeta := dst.service.Metadata(), src.service.Metadata()
+	now := time.Now()
+	for _, c := range changes {
+		switch c.Kind {
+		case diff.Added:
+			dst.secrets[c.Key] = c.New
+			meta.SetEncoding(c.Key, srcMeta.Encoding(c.Key))
+		case diff.Changed:
+			if !overwrite {
+				skipped = append(skipped, c)
+				continue
+			}
+			meta.PushHistory(c.Key, c.Old, now)
+			dst.secrets[c.Key] = c.New
+			meta.SetEncoding(c.Key, srcMeta.Encoding(c.Key))
+		case diff.Removed:
+			if !prune {
+				skipped = append(skipped, c)
+				continue
+			}
+			delete(dst.secrets, c.Key)
+			meta.Forget(c.Key)
+		}
+		applied = append(applied, c)
+	}
+	return applied, skipped
+}
+
+// checkKeyPolicy refuses to add keys that the target environment's naming
+// policy rejects; keys already in the target are left alone.
+func checkKeyPolicy(env string, changes []diff.Change, allowReserved bool) error {
+	policy := keyPolicy(env, allowReserved)
+	var bad []string
+	for _, c := range changes {
+		if c.Kind != diff.Added {
+			continue
+		}
+		if err := policy.ValidateKey(c.Key); err != nil {
+			bad = append(bad, err.Error())
+		}
+	}
+	if len(bad) > 0 {
+		return fmt.Errorf("keys not allowed in %s:\n  %s", env, strings.Join(bad, "\n  "))
+	}
+	return nil
+}
+
+// logTransaction records the source read and one entry per applied key on
+// the target, all under tx. It returns the first write failure.
+func logTransaction(tx *audit.Transaction, action string, src, dst *envVault, applied []diff.Change, err error) error {
+	success, msg := err == nil, ""
+	if err != nil {
+		msg = err.Error()
+	}
+	first := tx.Log(action, src.path, src.env, "", success, msg)
+	for _, c := range applied {
+		if logErr := tx.Log(action, dst.path, dst.env, c.Key, success, msg); first == nil {
+			first = logErr
+		}
+	}
+	return first
+}
+
+// commitTransaction writes the entries before the target is saved when
+// audit.fail_closed is set, and fails if they cannot be written. It reports
+// whether it wrote them, so that only a later failure is logged again.
+func commitTransaction(tx *audit.Transaction, action string, src, dst *envVault, applied []diff.Change) (bool, error) {
+	if !audit.FailClosed() {
+		return false, nil
+	}
+	if err := logTransaction(tx, action, src, dst, applied, nil); err != nil {
+		return true, fmt.Errorf("audit log unavailable (audit.fail_closed is set): %w", err)
+	}
+	return true, nil
+}
+
+func openPair(from, to, fromPass, toPass string) (*envVault, *envVault, error) {
+	if from == to {
+		return nil, nil, fmt.Errorf("source and target are both %s", from)
+	}
+	fromPath, _, _ := vault.GetVaultPath(from)
+	toPath, _, _ := vault.GetVaultPath(to)
+	if fromPath == toPath {
+		return nil, nil, fmt.Errorf("%s and %s resolve to the same vault %s (environments need a project vault directory)", from, to, fromPath)
+	}
+	src, err := openEnvVault(from, fromPass, fromPassEnv, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	dst, err := openEnvVault(to, toPass, toPassEnv, true)
+	if err != nil {
+		src.close()
+		return nil, nil, err
+	}
+	return src, dst, nil
+}
+
+func (h *handlers) handleCopy(keys []string, opts copyOptions) (err error) {
+	tx := audit.Begin()
+	from, to := resolveEnvName(opts.From), opts.To
+	if to == "" {
+		return fmt.Errorf("--to is required")
+	}
+	if len(keys) == 0 && opts.Pattern == "" {
+		return fmt.Errorf("name the keys to copy or pass --pattern (use --pattern '*' for all)")
+	}
+	audit.NoteKeys(keys...)
+	src, dst, err := openPair(from, to, opts.FromPass, opts.ToPass)
+	if err != nil {
+		tx.Log(audit.ActionCopy, "", from, "", false, err.Error())
+		return err
+	}
+	defer src.close()
+	defer dst.close()
+	var applied []diff.Change
+	committed := false
+	defer func() {
+		if !committed || err != nil {
+			logTransaction(tx, audit.ActionCopy, src, dst, applied, err)
+		}
+	}()
+
+	selected := make(map[string]string)
+	if opts.Pattern != "" {
+		for k, v := range keyfilter.Apply(src.secrets, keyfilter.Split(opts.Pattern)) {
+			selected[k] = v
+		}
+	}
+	var missing []string
+	for _, k := range keys {
+		v, ok := src.secrets[k]
+		if !ok {
+			missing = append(missing, k)
+			continue
+		}
+		selected[k] = v
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("not found in %s: %s", from, strings.Join(missing, ", "))
+	}
+	if len(selected) == 0 {
+		return fmt.Errorf("no keys in %s match %s", from, opts.Pattern)
+	}
+
+	current := make(map[string]string)
+	for k := range selected {
+		if v, ok := dst.secrets[k]; ok {
+			current[k] = v
+		}
+	}
+	result := diff.Compare(current, selected, diff.ModeHash)
+	if err = checkKeyPolicy(to, result.Changes, opts.AllowReserved); err != nil {
+		return err
+	}
+	var skipped []diff.Change
+	applied, skipped = applyChanges(dst, src, result.Changes, opts.Overwrite, false)
+
+	if opts.DryRun {
+		fmt.Printf("Dry run: copy %s -> %s (vault not modified)\n", from, to)
+	} else if len(applied) > 0 {
+		if committed, err = commitTransaction(tx, audit.ActionCopy, src, dst, applied); err != nil {
+			return err
+		}
+		if err = dst.save(); err != nil {
+			return fmt.Errorf("failed to save %s: %w", to, err)
+		}
+	}
+	for _, c := range applied {
+		if c.Kind == diff.Added {
+			fmt.Printf("+ %s\n", c.Key)
+		} else {
+			fmt.Printf("~ %s (overwritten; previous value kept in history)\n", c.Key)
+		}
+	}
+	for _, c := range skipped {
+		fmt.Printf("! %s exists in %s with a different value (use --overwrite)\n", c.Key, to)
+	}
+	verb := "Copied"
+	if opts.DryRun {
+		verb = "Would copy"
+	}
+	fmt.Printf("%s %d keys from %s to %s (%d skipped, %d unchanged)\n", verb, len(applied), from, to, len(skipped), result.Unchanged)
+	if opts.DryRun {
+		applied = nil
+	}
+	return nil
+}
+
+func (h *handlers) handlePromote(opts promoteOptions) (err error) {
+	tx := audit.Begin()
+	var plan *diff.Plan
+	if opts.Plan != "" {
+		if plan, err = diff.LoadPlan(opts.Plan); err != nil {
+			return err
+		}
+		if (opts.From != "" && opts.From != plan.From) || (opts.To != "" && opts.To != plan.To) {
+			return fmt.Errorf("plan promotes %s -> %s; --from/--to do not match", plan.From, plan.To)
+		}
+		opts.From, opts.To, opts.Prune = plan.From, plan.To, plan.Prune
+		opts.Pattern = strings.Join(plan.Patterns, ",")
+	}
+	from, to := resolveEnvName(opts.From), opts.To
+	if to == "" {
+		return fmt.Errorf("--to is required")
+	}
+	src, dst, err := openPair(from, to, opts.FromPass, opts.ToPass)
+	if err != nil {
+		tx.Log(audit.ActionPromote, "", from, "", false, err.Error())
+		return err
+	}
+	defer src.close()
+	defer dst.close()
+	var applied []diff.Change
+	committed := false
+	defer func() {
+		if !committed || err != nil {
+			logTransaction(tx, audit.ActionPromote, src, dst, applied, err)
+		}
+	}()
+
+	patterns := keyfilter.Split(opts.Pattern)
+	result := diff.Compare(keyfilter.Apply(dst.secrets, patterns), keyfilter.Apply(src.secrets, patterns), diff.ModeHash)
+	fromFP, err := src.service.Fingerprint()
+	if err != nil {
+		return fmt.Errorf("failed to fingerprint %s: %w", from, err)
+	}
+	toFP := ""
+	if dst.service.Exists() {
+		if toFP, err = dst.service.Fingerprint(); err != nil {
+			return fmt.Errorf("failed to fingerprint %s: %w", to, err)
+		}
+	}
+	if plan != nil {
+		if err = plan.Verify(fromFP, toFP, result.Changes); err != nil {
+			return fmt.Errorf("%w; create a new plan with --out", err)
+		}
+	}
+
+	if err = checkKeyPolicy(to, result.Changes, opts.AllowReserved); err != nil {
+		return err
+	}
+
+	fmt.Printf("Promote %s -> %s\n", from, to)
+	printDiff(result, diff.ModeHash, false)
+	if result.Empty() {
+		fmt.Println("Nothing to promote")
+		return nil
+	}
+	if n := result.Count(diff.Removed); n > 0 && !opts.Prune {
+		fmt.Printf("(%d keys only in %s are kept; pass --prune to delete them)\n", n, to)
+	}
+
+	if opts.Out != "" {
+		p := &diff.Plan{
+			Version:         diff.PlanVersion,
+			CreatedAt:       time.Now().UTC().Truncate(time.Second),
+			From:            from,
+			To:              to,
+			FromFingerprint: fromFP,
+			ToFingerprint:   toFP,
+			Patterns:        patterns,
+			Prune:           opts.Prune,
+		}
+		for _, c := range result.Changes {
+			p.Changes = append(p.Changes, diff.PlanChange{Key: c.Key, Kind: c.Kind})
+		}
+		if err = p.Save(opts.Out); err != nil {
+			return err
+		}
+		fmt.Printf("Plan written to %s; apply it with: ghostenv promote --plan %s\n", opts.Out, opts.Out)
+		return nil
+	}
+	if plan == nil && !opts.Yes {
+		ok, confirmErr := confirm(fmt.Sprintf("Apply these changes to %s?", to))
+		if confirmErr != nil {
+			return confirmErr
+		}
+		if !ok {
+			return fmt.Errorf("promotion cancelled")
+		}
+	}
+
+	applied, _ = applyChanges(dst, src, result.Changes, true, opts.Prune)
+	if committed, err = commitTransaction(tx, audit.ActionPromote, src, dst, applied); err != nil {
+		return err
+	}
+	if err = dst.save(); err != nil {
+		return fmt.Errorf("failed to save %s: %w", to, err)
+	}
+	fmt.Printf("Promoted %d changes from %s to %s (transaction %s)\n", len(applied), from, to, tx.ID)
+	return nil
+}
+
+// confirm asks a yes/no question on the terminal. Without a terminal it
+// refuses, so unattended promotion needs an explicit --yes or a plan.
+func confirm(question string) (bool, error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return false, fmt.Errorf("refusing to apply without review: pass --yes, or use --out and --plan")
+	}
+	fmt.Printf("%s [y/N] ", question)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return false, nil
+	}
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "y" || answer == "yes", nil
+}
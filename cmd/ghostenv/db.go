@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/SrPlugin/GhostEnv/internal/audit"
+	"github.com/SrPlugin/GhostEnv/internal/config"
+	"github.com/SrPlugin/GhostEnv/internal/postgres"
+	"github.com/SrPlugin/GhostEnv/internal/vault"
+)
+
+// psqlBinaryEnv overrides the psql binary, e.g. to point at a wrapper.
+const psqlBinaryEnv = "GHOSTENV_PSQL"
+
+func (h *handlers) loadPostgresCredentials(password []byte, environment string) (postgres.Credentials, error) {
+	vaultService, err := h.getVaultService(environment)
+	if err != nil {
+		return postgres.Credentials{}, fmt.Errorf("failed to resolve vault: %w", err)
+	}
+	cfg := config.Current()
+	if cfg == nil || !cfg.Microservices.Postgres.Enabled {
+		return postgres.Credentials{}, fmt.Errorf("postgres integration is disabled (set microservices.postgres.enabled in %s)", config.ProjectConfigName)
+	}
+	// The same secrets run builds DATABASE_URL from.
+	secrets, err := loadInjectable(vaultService, password)
+	if err != nil {
+		return postgres.Credentials{}, err
+	}
+	return postgres.FromConfig(cfg.Microservices.Postgres, secrets)
+}
+
+func (h *handlers) handleDBURL(password []byte, environment string) (err error) {
+	defer zeroBytes(password)
+	vaultPath, _, _ := vault.GetVaultPath(environment)
+	rec := newAuditRecord(audit.ActionDB, vaultPath, environment, "url")
+	defer func() { rec.done(err) }()
+	creds, err := h.loadPostgresCredentials(password, environment)
+	if err != nil {
+		return err
+	}
+	if err = rec.commit(); err != nil {
+		return err
+	}
+	fmt.Println(creds.MaskedURL())
+	return nil
+}
+
+// handleDBPsql starts psql with the credentials in PG* variables only, so the
+// password never appears in argv.
+func (h *handlers) handleDBPsql(args []string, password []byte, environment string) (err error) {
+	defer zeroBytes(password)
+	vaultPath, _, _ := vault.GetVaultPath(environment)
+	rec := newAuditRecord(audit.ActionDB, vaultPath, environment, "psql")
+	defer func() { rec.done(err) }()
+	creds, err := h.loadPostgresCredentials(password, environment)
+	if err != nil {
+		return err
+	}
+	if err = rec.commit(); err != nil {
+		return err
+	}
+	bin := os.Getenv(psqlBinaryEnv)
+	if bin == "" {
+		bin = "psql"
+	}
+	if err = h.runner.Run(bin, args, creds.Env()); err != nil {
+		return fmt.Errorf("psql failed: %w", err)
+	}
+	return nil
+}
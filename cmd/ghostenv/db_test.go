@@ -0,0 +1,112 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/SrPlugin/GhostEnv/internal/config"
+	"github.com/SrPlugin/GhostEnv/internal/injector"
+	"github.com/SrPlugin/GhostEnv/internal/vault"
+)
+
+const testPostgresConfig = `project:
+  name: dbtest
+security:
+  argon2:
+    memory: 8MB
+    iterations: 1
+    parallelism: 1
+microservices:
+  postgres:
+    enabled: true
+    host: db.internal
+    database: app
+    user_key: DB_USER
+    pass_key: DB_PASS
+`
+
+// fakePsql writes a psql stand-in that records its environment and
+// arguments in dir.
+func fakePsql(t *testing.T, dir string) string {
+	t.Helper()
+	script := filepath.Join(dir, "psql")
+	body := "#!/bin/sh\nenv > \"$FAKE_PSQL_OUT/env\"\nprintf '%s\\n' \"$@\" > \"$FAKE_PSQL_OUT/args\"\n"
+	if err := os.WriteFile(script, []byte(body), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("FAKE_PSQL_OUT", dir)
+	return script
+}
+
+// testProject moves into a fresh project with projectConfig as its
+// .ghostenv.yml and a home directory of its own, and returns the service for
+// the development vault.
+func testProject(t *testing.T, projectConfig string) vault.Service {
+	t.Helper()
+	home := t.TempDir()
+	project := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Chdir(project)
+	if err := os.WriteFile(filepath.Join(project, config.ProjectConfigName), []byte(projectConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+	svc, err := getVaultService("development")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return svc
+}
+
+func TestDBPsqlPassesCredentialsInEnvironment(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake psql is a shell script")
+	}
+	svc := testProject(t, testPostgresConfig)
+	secrets := map[string]string{"DB_USER": "app", "DB_PASS": "s3cret pass"}
+	if err := svc.Save(secrets, []byte("master")); err != nil {
+		t.Fatal(err)
+	}
+
+	out := t.TempDir()
+	t.Setenv(psqlBinaryEnv, fakePsql(t, out))
+
+	h := newHandlers(injector.NewRunner())
+	if err := h.handleDBPsql([]string{"-c", "select 1"}, []byte("master"), "development"); err != nil {
+		t.Fatalf("handleDBPsql: %v", err)
+	}
+
+	env, err := os.ReadFile(filepath.Join(out, "env"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"PGHOST=db.internal", "PGDATABASE=app", "PGUSER=app", "PGPASSWORD=s3cret pass"} {
+		if !strings.Contains(string(env), want+"\n") {
+			t.Errorf("psql environment is missing %s", want)
+		}
+	}
+	args, err := os.ReadFile(filepath.Join(out, "args"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(args); got != "-c\nselect 1\n" {
+		t.Errorf("psql args = %q, want only the passed arguments", got)
+	}
+	if strings.Contains(string(args), "s3cret") {
+		t.Error("password appears in psql arguments")
+	}
+}
+
+func TestDBPsqlWithoutPostgresIntegration(t *testing.T) {
+	testProject(t, "project:\n  name: dbtest\n")
+	t.Setenv(psqlBinaryEnv, filepath.Join(t.TempDir(), "never-run"))
+
+	h := newHandlers(injector.NewRunner())
+	err := h.handleDBPsql(nil, []byte("master"), "development")
+	if err == nil || !strings.Contains(err.Error(), "postgres integration is disabled") {
+		t.Fatalf("handleDBPsql error = %v, want the integration to be reported as disabled", err)
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/SrPlugin/GhostEnv/internal/audit"
+	"github.com/SrPlugin/GhostEnv/internal/config"
+	"github.com/SrPlugin/GhostEnv/internal/diff"
+	"github.com/SrPlugin/GhostEnv/internal/dotenv"
+	"github.com/SrPlugin/GhostEnv/internal/format"
+	"github.com/SrPlugin/GhostEnv/internal/storage"
+	"github.com/SrPlugin/GhostEnv/internal/vault"
+)
+
+const (
+	fromPassEnv = "GHOSTENV_FROM_PASS"
+	toPassEnv   = "GHOSTENV_TO_PASS"
+)
+
+type diffOptions struct {
+	From       string
+	To         string
+	Against    string
+	By         string
+	ShowValues bool
+	FromPass   string
+	ToPass     string
+}
+
+// errSecretsDiffer is returned by diff when the two sides differ; main turns
+// it into exit status 1 once the audit check has run.
+var errSecretsDiffer = errors.New("secrets differ")
+
+// envVault is one environment's vault opened with its own password, for
+// commands that work on two vaults at once. close zeroes the password.
+type envVault struct {
+	env     string
+	path    string
+	service vault.Service
+	secrets map[string]string
+	pw      []byte
+}
+
+// openEnvVault loads env's vault. With create set a missing vault is not an
+// error; it starts empty and is created by the first Save, and a prompted
+// password for it must be confirmed.
+func openEnvVault(env, passFlag, passEnv string, create bool) (*envVault, error) {
+	path, _, err := vault.GetVaultPath(env)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve vault for %s: %w", env, err)
+	}
+	service, err := getVaultService(env)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve vault for %s: %w", env, err)
+	}
+	v := &envVault{env: env, path: path, service: service, secrets: make(map[string]string)}
+	exists := service.Exists()
+	if !exists && !create {
+		return nil, fmt.Errorf("vault for %s not found", env)
+	}
+	getEnvPw := getEnvPassword
+	if !exists {
+		getEnvPw = getNewEnvPassword
+	}
+	if v.pw, err = getEnvPw(env, passFlag, passEnv); err != nil {
+		return nil, fmt.Errorf("password error: %w", err)
+	}
+	if !exists {
+		return v, nil
+	}
+	secrets, err := service.Load(v.pw)
+	if err != nil {
+		v.close()
+		if err == storage.ErrVaultNotFound {
+			return nil, fmt.Errorf("vault for %s not found", env)
+		}
+		return nil, fmt.Errorf("failed to load %s: %w", env, err)
+	}
+	v.secrets = secrets
+	noteKeys(secrets)
+	return v, nil
+}
+
+func (v *envVault) save() error {
+	return v.service.Save(v.secrets, v.pw)
+}
+
+func (v *envVault) close() {
+	zeroBytes(v.pw)
+}
+
+func loadEnvSecrets(env, passFlag, passEnv string) (map[string]string, error) {
+	v, err := openEnvVault(env, passFlag, passEnv, false)
+	if err != nil {
+		return nil, err
+	}
+	v.close()
+	return v.secrets, nil
+}
+
+func loadSecretsFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	defer zeroBytes(data)
+	f := format.Detect(path, data)
+	entries, err := f.Decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s as %s: %w", path, f.Name(), err)
+	}
+	out := make(map[string]string, len(entries))
+	for _, e := range entries {
+		out[e.Key] = e.Value
+	}
+	return out, nil
+}
+
+// resolveEnvName names the default environment when env is empty. The config
+// is loaded by the first vault path lookup, so call it after one.
+func resolveEnvName(env string) string {
+	if env != "" {
+		return env
+	}
+	if c := config.Current(); c != nil && c.Project.DefaultEnv != "" {
+		return c.Project.DefaultEnv
+	}
+	return config.DefaultEnvironment
+}
+
+// handleDiff reports whether the two sides differ; the command turns that
+// into exit status 1, as diff(1) does.
+func (h *handlers) handleDiff(opts diffOptions) (differ bool, err error) {
+	vaultPath, _, _ := vault.GetVaultPath(opts.From)
+	from := resolveEnvName(opts.From)
+	target := opts.To
+	if opts.Against != "" {
+		target = opts.Against
+	}
+	rec := newAuditRecord(audit.ActionDiff, vaultPath, from, "-> "+target)
+	rec.ctx.Reveals = opts.ShowValues
+	defer func() { rec.done(err) }()
+
+	switch {
+	case opts.To != "" && opts.Against != "":
+		return false, fmt.Errorf("use either --to or --against, not both")
+	case opts.To == "" && opts.Against == "":
+		return false, fmt.Errorf("nothing to compare: pass --to ENV or --against FILE")
+	}
+	mode, err := diff.ParseMode(opts.By)
+	if err != nil {
+		return false, err
+	}
+
+	fromSecrets, err := loadEnvSecrets(from, opts.FromPass, fromPassEnv)
+	if err != nil {
+		return false, err
+	}
+	var toSecrets map[string]string
+	if opts.Against != "" {
+		toSecrets, err = loadSecretsFile(opts.Against)
+	} else {
+		toSecrets, err = loadEnvSecrets(opts.To, opts.ToPass, toPassEnv)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	result := diff.Compare(fromSecrets, toSecrets, mode)
+	if opts.ShowValues {
+		if err = rec.commit(); err != nil {
+			return false, err
+		}
+	}
+	fmt.Printf("--- %s\n+++ %s\n", from, target)
+	printDiff(result, mode, opts.ShowValues)
+	fmt.Printf("%d added, %d removed, %d changed, %d unchanged\n",
+		result.Count(diff.Added), result.Count(diff.Removed), result.Count(diff.Changed), result.Unchanged)
+	return !result.Empty(), nil
+}
+
+func printDiff(r diff.Result, mode diff.Mode, showValues bool) {
+	for _, c := range r.Changes {
+		switch c.Kind {
+		case diff.Added:
+			if showValues {
+				fmt.Printf("+ %s=%s\n", c.Key, dotenv.Quote(c.New))
+			} else {
+				fmt.Printf("+ %s\n", c.Key)
+			}
+		case diff.Removed:
+			if showValues {
+				fmt.Printf("- %s=%s\n", c.Key, dotenv.Quote(c.Old))
+			} else {
+				fmt.Printf("- %s\n", c.Key)
+			}
+		case diff.Changed:
+			switch {
+			case showValues:
+				fmt.Printf("~ %s: %s -> %s\n", c.Key, dotenv.Quote(c.Old), dotenv.Quote(c.New))
+			case mode == diff.ModeLength:
+				fmt.Printf("~ %s (length %d -> %d)\n", c.Key, len(c.Old), len(c.New))
+			default:
+				fmt.Printf("~ %s (value differs)\n", c.Key)
+			}
+		}
+	}
+}
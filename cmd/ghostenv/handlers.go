@@ -1,16 +1,27 @@
 package main
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/SrPlugin/GhostEnv/internal/audit"
 	"github.com/SrPlugin/GhostEnv/internal/config"
+	"github.com/SrPlugin/GhostEnv/internal/dotenv"
+	"github.com/SrPlugin/GhostEnv/internal/format"
+	"github.com/SrPlugin/GhostEnv/internal/generate"
 	"github.com/SrPlugin/GhostEnv/internal/injector"
+	"github.com/SrPlugin/GhostEnv/internal/keyfilter"
+	"github.com/SrPlugin/GhostEnv/internal/postgres"
+	"github.com/SrPlugin/GhostEnv/internal/render"
 	"github.com/SrPlugin/GhostEnv/internal/shamir"
 	"github.com/SrPlugin/GhostEnv/internal/storage"
 	"github.com/SrPlugin/GhostEnv/internal/validator"
@@ -32,20 +43,106 @@ func (h *handlers) getVaultService(environment string) (vault.Service, error) {
 }
 
 func auditLog(action, vaultPath, env, key string, err error) {
+	_ = auditLogContext(audit.Context{}, action, vaultPath, env, key, err)
+}
+
+// noteKeys lets audit.mask_keys mask the names of secrets in error messages
+// and run commands as well.
+func noteKeys(secrets map[string]string) {
+	audit.NoteKeys(slices.Collect(maps.Keys(secrets))...)
+}
+
+func auditLogContext(ctx audit.Context, action, vaultPath, env, key string, err error) error {
 	success := err == nil
 	msg := ""
 	if err != nil {
 		msg = err.Error()
 	}
-	audit.Log(action, vaultPath, env, key, success, msg)
+	// Under audit.fail_closed a failure is kept by the audit package and
+	// turns the command's exit status into an error in main.
+	return ctx.Log(action, vaultPath, env, key, success, msg)
+}
+
+// auditRecord is the audit entry of one command. Under audit.fail_closed
+// the command writes it with commit before it reveals secrets or saves a
+// vault, and stops if the write fails; done then only logs a failure that
+// came later. Otherwise done writes the entry when the command returns.
+type auditRecord struct {
+	ctx       audit.Context
+	action    string
+	vaultPath string
+	env       string
+	key       string
+	committed bool
+}
+
+func newAuditRecord(action, vaultPath, env, key string) *auditRecord {
+	return &auditRecord{action: action, vaultPath: vaultPath, env: env, key: key}
+}
+
+func (r *auditRecord) commit() error {
+	if !audit.FailClosed() || r.committed {
+		return nil
+	}
+	r.committed = true
+	if err := r.ctx.Log(r.action, r.vaultPath, r.env, r.key, true, ""); err != nil {
+		return fmt.Errorf("audit log unavailable (audit.fail_closed is set): %w", err)
+	}
+	return nil
+}
+
+func (r *auditRecord) done(err error) {
+	if r.committed && err == nil {
+		return
+	}
+	_ = auditLogContext(r.ctx, r.action, r.vaultPath, r.env, r.key, err)
+}
+
+// keyPolicy builds env's naming policy from the validation config. Prefixes
+// under "*" apply to environments without their own list.
+func keyPolicy(env string, allowReserved bool) validator.Policy {
+	p := validator.Policy{AllowReserved: allowReserved}
+	c := config.Current()
+	if c == nil {
+		return p
+	}
+	p.Pattern, p.Reserved = c.Validation.KeyPattern, c.Validation.Reserved
+	p.Prefixes = c.Validation.Prefixes[env]
+	if p.Prefixes == nil {
+		p.Prefixes = c.Validation.Prefixes["*"]
+	}
+	return p
+}
+
+func keyError(err error) error {
+	if errors.Is(err, validator.ErrReservedKey) {
+		return fmt.Errorf("invalid key: %w (pass --allow-reserved to store it anyway)", err)
+	}
+	return fmt.Errorf("invalid key: %w", err)
+}
+
+// auditPreflight refuses to start a command when the config is invalid, or
+// when audit.fail_closed is set and the audit sink cannot be written.
+func auditPreflight(environment string) error {
+	vaultPath, _, err := vault.GetVaultPath(environment)
+	if err != nil {
+		return err
+	}
+	return audit.Preflight(vaultPath)
 }
 
-func (h *handlers) handleSet(key, value string, password []byte, environment string) (err error) {
+func auditFailure() error {
+	return audit.Err()
+}
+
+func (h *handlers) handleSet(key string, value []byte, password []byte, environment string, allowReserved bool) (err error) {
 	defer zeroBytes(password)
+	defer zeroBytes(value)
 	vaultPath, _, _ := vault.GetVaultPath(environment)
-	defer func() { auditLog(audit.ActionSet, vaultPath, environment, key, err) }()
-	if err = validator.ValidateKey(key); err != nil {
-		return fmt.Errorf("invalid key: %w", err)
+	rec := newAuditRecord(audit.ActionSet, vaultPath, environment, key)
+	defer func() { rec.done(err) }()
+	if err = keyPolicy(resolveEnvName(environment), allowReserved).ValidateKey(key); err != nil {
+		return keyError(err)
 	}
 
 	vaultService, err := h.getVaultService(environment)
@@ -64,19 +161,287 @@ func (h *handlers) handleSet(key, value string, password []byte, environment str
 		}
 	}
 
-	secrets[key] = value
+	stored, encoding := vault.EncodeValue(value)
+	if rule, ok := schemaRules(resolveEnvName(environment))[key]; ok && encoding == "" {
+		if err = validator.ValidateValue(stored, rule); err != nil {
+			return fmt.Errorf("%s does not match the schema: %w", key, err)
+		}
+	}
+	secrets[key] = stored
+	vaultService.Metadata().SetEncoding(key, encoding)
+	if err = rec.commit(); err != nil {
+		return err
+	}
 	if err = vaultService.Save(secrets, password); err != nil {
 		return fmt.Errorf("failed to save secret: %w", err)
 	}
 
-	fmt.Printf("Secret '%s' saved successfully\n", key)
+	if encoding != "" {
+		fmt.Printf("Secret '%s' saved successfully (binary, stored %s-encoded)\n", key, encoding)
+	} else {
+		fmt.Printf("Secret '%s' saved successfully\n", key)
+	}
+	return nil
+}
+
+// handleSetGenerated stores a freshly generated value; it is printed only
+// when show is set.
+func (h *handlers) handleSetGenerated(key string, opts generate.Options, show bool, password []byte, environment string, allowReserved bool) error {
+	value, err := generate.Generate(opts)
+	if err != nil {
+		zeroBytes(password)
+		return fmt.Errorf("failed to generate value: %w", err)
+	}
+	if err := h.handleSet(key, []byte(value), password, environment, allowReserved); err != nil {
+		return err
+	}
+	if show {
+		fmt.Println(strings.TrimRight(value, "\n"))
+	}
+	return nil
+}
+
+type runOptions struct {
+	Templates   []string
+	Watch       bool
+	GracePeriod time.Duration
+	Strict      bool
+}
+
+func (h *handlers) handleRun(command string, args []string, password []byte, environment string, opts runOptions) (err error) {
+	defer zeroBytes(password)
+	vaultPath, _, _ := vault.GetVaultPath(environment)
+	argv := append([]string{command}, args...)
+	rec := newAuditRecord(audit.ActionRun, vaultPath, environment, command)
+	rec.ctx.Command = audit.SanitizeArgv(argv, nil)
+	defer func() { rec.done(err) }()
+	vaultService, err := h.getVaultService(environment)
+	if err != nil {
+		return fmt.Errorf("failed to resolve vault: %w", err)
+	}
+
+	load := func() (map[string]string, error) {
+		secrets, err := loadInjectable(vaultService, password)
+		if err != nil {
+			return nil, err
+		}
+		injectPostgres(secrets)
+		return secrets, nil
+	}
+	if opts.Watch {
+		// Reloads decrypt with the keys derived here; Argon2id only runs
+		// again when a save gave the vault a new salt. They load the same
+		// secrets as a plain run: the shared vault is watched (see
+		// watchPaths) but not injected.
+		keys := newReloadKeys(password, []string{vaultPath})
+		defer keys.close()
+		load = func() (map[string]string, error) {
+			// unlock puts the key for the vault's current salt in the key
+			// cache, which is what a nil password makes Load use.
+			keys.unlock()
+			secrets, err := loadInjectable(vaultService, nil)
+			if err != nil {
+				return nil, err
+			}
+			injectPostgres(secrets)
+			return secrets, nil
+		}
+	}
+
+	secrets, err := load()
+	if err != nil {
+		return err
+	}
+	rec.ctx.Command = audit.SanitizeArgv(argv, secrets)
+	if opts.Strict {
+		if err = checkStrict(secrets, environment); err != nil {
+			return err
+		}
+	}
+	if err = rec.commit(); err != nil {
+		return err
+	}
+
+	if len(opts.Templates) > 0 {
+		rendered, err := renderTemplates(opts.Templates, secrets)
+		defer removeFiles(rendered)
+		if err != nil {
+			return err
+		}
+		if !opts.Watch {
+			return h.runUntilStopped(command, args, secrets, opts.GracePeriod)
+		}
+	}
+
+	if opts.Watch {
+		return h.runWatch(command, args, secrets, load, vaultPath, environment, opts)
+	}
+
+	if err = h.runner.Run(command, args, secrets); err != nil {
+		return fmt.Errorf("command execution failed: %w", err)
+	}
+
 	return nil
 }
 
-func (h *handlers) handleRun(command string, args []string, password []byte, environment string) (err error) {
+// stopSignals are trapped by the commands that supervise a child (run
+// --watch, run with templates, up), so the children are stopped and rendered
+// files removed before ghostenv exits.
+var stopSignals = []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGHUP}
+
+// runUntilStopped runs the command like Runner.Run but stays in control when
+// a stop signal arrives, so that the caller's cleanup still runs. An
+// interrupt from the terminal also reaches the child and is left to it;
+// SIGTERM and SIGHUP are passed on by stopping the child, and reported as
+// an error.
+func (h *handlers) runUntilStopped(command string, args []string, secrets map[string]string, grace time.Duration) error {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, stopSignals...)
+	defer signal.Stop(sig)
+
+	proc, err := h.runner.Start(command, args, secrets, injector.StartOptions{Interactive: true})
+	if err != nil {
+		return fmt.Errorf("command execution failed: %w", err)
+	}
+	for {
+		select {
+		case <-proc.Done():
+			if err := proc.Wait(); err != nil {
+				return fmt.Errorf("command execution failed: %w", err)
+			}
+			return nil
+		case s := <-sig:
+			if s == os.Interrupt {
+				continue
+			}
+			// Exit non-zero like a child that was killed by the signal,
+			// even if it handled SIGTERM and exited cleanly.
+			_ = proc.Stop(grace)
+			return fmt.Errorf("command stopped on %v", s)
+		}
+	}
+}
+
+// checkStrict refuses secrets that miss or break the environment's schema,
+// listing the problems on stderr.
+func checkStrict(secrets map[string]string, environment string) error {
+	env := resolveEnvName(environment)
+	problems := validator.Check(secrets, schemaRules(env))
+	if len(problems) == 0 {
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "ghostenv: %s does not match its schema:\n", env)
+	printProblems(os.Stderr, problems)
+	return fmt.Errorf("refusing to start with %d schema problems (see ghostenv check)", len(problems))
+}
+
+// loadRunSecrets loads the environment vault on top of the shared vault when
+// microservices.inheritance is enabled, so project keys win.
+func loadRunSecrets(vaultService vault.Service, password []byte) (map[string]string, error) {
+	secrets := make(map[string]string)
+	if shared := vault.SharedVaultPath(); shared != "" {
+		sharedService := vault.NewService(shared)
+		if sharedService.Exists() {
+			sharedSecrets, err := sharedService.Load(password)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load shared vault: %w", err)
+			}
+			noteKeys(sharedSecrets)
+			for k, v := range sharedSecrets {
+				secrets[k] = v
+			}
+		}
+	}
+
+	envSecrets, err := loadVaultSecrets(vaultService, password)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range envSecrets {
+		secrets[k] = v
+	}
+	dropUninjectable(secrets)
+	return secrets, nil
+}
+
+// loadInjectable loads what plain run injects before the Postgres
+// variables: the environment vault without the keys and values that cannot
+// be passed through the environment. db builds its credentials from it too.
+func loadInjectable(vaultService vault.Service, password []byte) (map[string]string, error) {
+	secrets, err := loadVaultSecrets(vaultService, password)
+	if err != nil {
+		return nil, err
+	}
+	dropUninjectable(secrets)
+	return secrets, nil
+}
+
+func loadVaultSecrets(vaultService vault.Service, password []byte) (map[string]string, error) {
+	secrets, err := vaultService.Load(password)
+	if err != nil {
+		if err == storage.ErrVaultNotFound {
+			return nil, fmt.Errorf("vault not found. Run 'set' first")
+		}
+		return nil, fmt.Errorf("failed to load vault: %w", err)
+	}
+	noteKeys(secrets)
+	return secrets, nil
+}
+
+// dropUninjectable removes keys and values that cannot be passed through the
+// environment, with a warning for each.
+func dropUninjectable(secrets map[string]string) {
+	for k, v := range secrets {
+		if err := validator.ValidateKey(k); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: not injecting %q: %v\n", k, err)
+			delete(secrets, k)
+		} else if err := validator.ValidateValue(v, validator.Rule{}); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: not injecting %s: %v\n", k, err)
+			delete(secrets, k)
+		}
+	}
+}
+
+// injectPostgres adds DATABASE_URL and PG* variables when
+// microservices.postgres is enabled. Only run does this; the other commands
+// that load a vault must not hand out the generated credentials.
+func injectPostgres(secrets map[string]string) {
+	if cfg := config.Current(); cfg != nil && cfg.Microservices.Postgres.Enabled {
+		creds, err := postgres.FromConfig(cfg.Microservices.Postgres, secrets)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: postgres integration skipped: %v\n", err)
+		} else {
+			creds.Inject(secrets)
+		}
+	}
+}
+
+func renderTemplates(specs []string, secrets map[string]string) ([]string, error) {
+	var written []string
+	for _, s := range specs {
+		spec, err := render.ParseSpec(s)
+		if err != nil {
+			return written, fmt.Errorf("invalid --template %q: %w", s, err)
+		}
+		if err := render.RenderFile(spec.Input, spec.Output, secrets); err != nil {
+			return written, err
+		}
+		written = append(written, spec.Output)
+	}
+	return written, nil
+}
+
+func removeFiles(paths []string) {
+	for _, p := range paths {
+		_ = os.Remove(p)
+	}
+}
+
+func (h *handlers) handleRender(templatePath, outputPath string, password []byte, environment string) (err error) {
 	defer zeroBytes(password)
 	vaultPath, _, _ := vault.GetVaultPath(environment)
-	defer func() { auditLog(audit.ActionRun, vaultPath, environment, command, err) }()
+	rec := newAuditRecord(audit.ActionRender, vaultPath, environment, templatePath)
+	defer func() { rec.done(err) }()
 	vaultService, err := h.getVaultService(environment)
 	if err != nil {
 		return fmt.Errorf("failed to resolve vault: %w", err)
@@ -85,15 +450,32 @@ func (h *handlers) handleRun(command string, args []string, password []byte, env
 	secrets, err := vaultService.Load(password)
 	if err != nil {
 		if err == storage.ErrVaultNotFound {
-			return fmt.Errorf("vault not found. Run 'set' first")
+			return fmt.Errorf("vault not found")
 		}
 		return fmt.Errorf("failed to load vault: %w", err)
 	}
 
-	if err = h.runner.Run(command, args, secrets); err != nil {
-		return fmt.Errorf("command execution failed: %w", err)
+	if err = rec.commit(); err != nil {
+		return err
+	}
+	if outputPath != "" {
+		if err = render.RenderFile(templatePath, outputPath, secrets); err != nil {
+			return err
+		}
+		fmt.Printf("Rendered %s to %s\n", templatePath, outputPath)
+		return nil
 	}
 
+	src, err := os.ReadFile(templatePath)
+	if err != nil {
+		return fmt.Errorf("failed to read template: %w", err)
+	}
+	out, err := render.Render(filepath.Base(templatePath), src, secrets)
+	if err != nil {
+		return err
+	}
+	defer zeroBytes(out)
+	os.Stdout.Write(out)
 	return nil
 }
 
@@ -122,10 +504,11 @@ func (h *handlers) handleList(password []byte, environment string) (err error) {
 	return nil
 }
 
-func (h *handlers) handleGet(key string, password []byte, environment string) (err error) {
+func (h *handlers) handleGet(key string, decode bool, password []byte, environment string) (err error) {
 	defer zeroBytes(password)
 	vaultPath, _, _ := vault.GetVaultPath(environment)
-	defer func() { auditLog(audit.ActionGet, vaultPath, environment, key, err) }()
+	rec := newAuditRecord(audit.ActionGet, vaultPath, environment, key)
+	defer func() { rec.done(err) }()
 	vaultService, err := h.getVaultService(environment)
 	if err != nil {
 		return fmt.Errorf("failed to resolve vault: %w", err)
@@ -139,18 +522,35 @@ func (h *handlers) handleGet(key string, password []byte, environment string) (e
 		return fmt.Errorf("failed to load vault: %w", err)
 	}
 
-	if val, ok := secrets[key]; ok {
-		fmt.Printf("%s = %s\n", key, val)
-	} else {
+	val, ok := secrets[key]
+	if !ok {
 		return fmt.Errorf("secret '%s' not found", key)
 	}
+	if err = rec.commit(); err != nil {
+		return err
+	}
+	encoding := vaultService.Metadata().Encoding(key)
+	if decode {
+		raw, err := vault.DecodeValue(val, encoding)
+		if err != nil {
+			return fmt.Errorf("failed to decode %s: %w", key, err)
+		}
+		defer zeroBytes(raw)
+		_, err = os.Stdout.Write(raw)
+		return err
+	}
+	fmt.Printf("%s = %s\n", key, val)
+	if encoding != "" {
+		fmt.Fprintf(os.Stderr, "(binary value, %s-encoded; use --decode for the raw bytes)\n", encoding)
+	}
 	return nil
 }
 
 func (h *handlers) handleRemove(key string, password []byte, environment string) (err error) {
 	defer zeroBytes(password)
 	vaultPath, _, _ := vault.GetVaultPath(environment)
-	defer func() { auditLog(audit.ActionRemove, vaultPath, environment, key, err) }()
+	rec := newAuditRecord(audit.ActionRemove, vaultPath, environment, key)
+	defer func() { rec.done(err) }()
 	vaultService, err := h.getVaultService(environment)
 	if err != nil {
 		return fmt.Errorf("failed to resolve vault: %w", err)
@@ -168,7 +568,11 @@ func (h *handlers) handleRemove(key string, password []byte, environment string)
 		return fmt.Errorf("secret '%s' not found", key)
 	}
 
+	if err = rec.commit(); err != nil {
+		return err
+	}
 	delete(secrets, key)
+	vaultService.Metadata().Forget(key)
 	if err := vaultService.Save(secrets, password); err != nil {
 		return fmt.Errorf("failed to save vault: %w", err)
 	}
@@ -177,19 +581,56 @@ func (h *handlers) handleRemove(key string, password []byte, environment string)
 	return nil
 }
 
-func (h *handlers) handleImport(filePath string, password []byte, environment string) (err error) {
+type importOptions struct {
+	Format        string
+	Bundle        bool
+	Identity      string
+	DryRun        bool
+	NoOverwrite   bool
+	Prune         bool
+	AllowReserved bool
+}
+
+type importReport struct {
+	Added     []string
+	Updated   []string
+	Unchanged []string
+	Skipped   []string
+	Removed   []string
+}
+
+func (h *handlers) handleImport(filePath string, opts importOptions, password []byte, environment string) (err error) {
 	defer zeroBytes(password)
 	vaultPath, _, _ := vault.GetVaultPath(environment)
-	defer func() { auditLog(audit.ActionImport, vaultPath, environment, filePath, err) }()
+	rec := newAuditRecord(audit.ActionImport, vaultPath, environment, filePath)
+	defer func() { rec.done(err) }()
 	vaultService, err := h.getVaultService(environment)
 	if err != nil {
 		return fmt.Errorf("failed to resolve vault: %w", err)
 	}
 
-	content, err := os.ReadFile(filePath)
+	data, err := os.ReadFile(filePath)
 	if err != nil {
 		return fmt.Errorf("failed to read file: %w", err)
 	}
+	defer zeroBytes(data)
+	var entries []dotenv.Entry
+	if opts.Bundle {
+		if entries, err = openBundle(data, opts.Identity); err != nil {
+			return err
+		}
+	} else {
+		f := format.Detect(filePath, data)
+		if opts.Format != "" {
+			if f, err = format.Lookup(opts.Format); err != nil {
+				return err
+			}
+		}
+		entries, err = f.Decode(data)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s as %s: %w", filePath, f.Name(), err)
+		}
+	}
 
 	secrets := make(map[string]string)
 	if vaultService.Exists() {
@@ -202,45 +643,143 @@ func (h *handlers) handleImport(filePath string, password []byte, environment st
 		}
 	}
 
-	lines := strings.Split(string(content), "\n")
-	count := 0
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" || strings.HasPrefix(line, "#") {
+	// Later assignments win, as when a shell sources the file.
+	incoming := make(map[string]string)
+	firstLine := make(map[string]int)
+	var order []string
+	var report importReport
+	policy := keyPolicy(resolveEnvName(environment), opts.AllowReserved)
+	for _, e := range entries {
+		err := policy.ValidateKey(e.Key)
+		if err == nil {
+			err = validator.ValidateValue(e.Value, validator.Rule{})
+		}
+		if err != nil {
+			if e.Line > 0 {
+				report.Skipped = append(report.Skipped, fmt.Sprintf("%s (line %d: %v)", e.Key, e.Line, err))
+			} else {
+				report.Skipped = append(report.Skipped, fmt.Sprintf("%s (%v)", e.Key, err))
+			}
+			continue
+		}
+		if prev, dup := firstLine[e.Key]; dup {
+			if e.Line > 0 {
+				fmt.Fprintf(os.Stderr, "Warning: line %d: duplicate key %s (first defined on line %d); last value wins\n", e.Line, e.Key, prev)
+			} else {
+				fmt.Fprintf(os.Stderr, "Warning: duplicate key %s; last value wins\n", e.Key)
+			}
+		} else {
+			firstLine[e.Key] = e.Line
+			order = append(order, e.Key)
+		}
+		incoming[e.Key] = e.Value
+	}
+
+	meta := vaultService.Metadata()
+	for _, key := range order {
+		val := incoming[key]
+		old, exists := secrets[key]
+		switch {
+		case !exists:
+			report.Added = append(report.Added, key)
+		case old == val && meta.Encoding(key) == "":
+			report.Unchanged = append(report.Unchanged, key)
+			continue
+		case opts.NoOverwrite:
+			report.Skipped = append(report.Skipped, fmt.Sprintf("%s (exists; --no-overwrite)", key))
 			continue
+		default:
+			report.Updated = append(report.Updated, key)
 		}
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) == 2 {
-			key := strings.TrimSpace(parts[0])
-			val := strings.Trim(strings.TrimSpace(parts[1]), `"'`)
-			if key != "" {
-				if err := validator.ValidateKey(key); err != nil {
-					continue
-				}
-				secrets[key] = val
-				count++
+		secrets[key] = val
+		meta.SetEncoding(key, "")
+	}
+	if opts.Prune {
+		for key := range secrets {
+			if _, ok := incoming[key]; !ok {
+				report.Removed = append(report.Removed, key)
 			}
 		}
+		sort.Strings(report.Removed)
+		for _, key := range report.Removed {
+			delete(secrets, key)
+			meta.Forget(key)
+		}
 	}
 
-	if err := vaultService.Save(secrets, password); err != nil {
-		return fmt.Errorf("failed to save vault: %w", err)
+	changed := len(report.Added)+len(report.Updated)+len(report.Removed) > 0
+	if !opts.DryRun && changed {
+		if err = rec.commit(); err != nil {
+			return err
+		}
+		if err = vaultService.Save(secrets, password); err != nil {
+			return fmt.Errorf("failed to save vault: %w", err)
+		}
 	}
 
-	fmt.Printf("Successfully imported %d secrets from %s\n", count, filePath)
+	printImportReport(filePath, report, opts)
 	return nil
 }
 
-func (h *handlers) handleExport(password []byte, environment, format, outputPath string) (err error) {
+func printImportReport(filePath string, r importReport, opts importOptions) {
+	if opts.DryRun {
+		fmt.Printf("Dry run: import from %s (vault not modified)\n", filePath)
+	} else {
+		fmt.Printf("Imported from %s\n", filePath)
+	}
+	fmt.Printf("  Added:     %d\n", len(r.Added))
+	fmt.Printf("  Updated:   %d\n", len(r.Updated))
+	fmt.Printf("  Unchanged: %d\n", len(r.Unchanged))
+	fmt.Printf("  Skipped:   %d\n", len(r.Skipped))
+	if opts.Prune {
+		fmt.Printf("  Removed:   %d\n", len(r.Removed))
+	}
+	if opts.DryRun {
+		for _, k := range r.Added {
+			fmt.Printf("  + %s\n", k)
+		}
+		for _, k := range r.Updated {
+			fmt.Printf("  ~ %s\n", k)
+		}
+		for _, k := range r.Removed {
+			fmt.Printf("  - %s\n", k)
+		}
+	}
+	for _, s := range r.Skipped {
+		fmt.Printf("  ! %s\n", s)
+	}
+}
+
+type exportOptions struct {
+	Format    string
+	Output    string
+	NoHeader  bool
+	Keys      string
+	Encrypt   bool
+	Recipient string
+	Expires   time.Duration
+}
+
+func (h *handlers) handleExport(password []byte, environment string, opts exportOptions) (err error) {
 	defer zeroBytes(password)
 	vaultPath, _, _ := vault.GetVaultPath(environment)
-	defer func() { auditLog(audit.ActionExport, vaultPath, environment, "", err) }()
-	if format == "" {
+	rec := newAuditRecord(audit.ActionExport, vaultPath, environment, "")
+	defer func() { rec.done(err) }()
+	formatName, outputPath := opts.Format, opts.Output
+	if opts.Encrypt && formatName != "" {
+		return fmt.Errorf("--format cannot be combined with --encrypt; bundles have their own format")
+	}
+	if formatName == "" && outputPath != "" {
+		if f, ok := format.FromPath(outputPath); ok {
+			formatName = f.Name()
+		}
+	}
+	if formatName == "" {
 		if c := config.Current(); c != nil && c.Export.DefaultFormat != "" {
-			format = c.Export.DefaultFormat
+			formatName = c.Export.DefaultFormat
 		}
-		if format == "" {
-			format = "json"
+		if formatName == "" {
+			formatName = "json"
 		}
 	}
 	vaultService, err := h.getVaultService(environment)
@@ -256,45 +795,100 @@ func (h *handlers) handleExport(password []byte, environment, format, outputPath
 		return fmt.Errorf("failed to load vault: %w", err)
 	}
 
-	var out []byte
-	switch format {
-	case "env":
-		var b strings.Builder
-		for k, v := range secrets {
-			b.WriteString(k)
-			b.WriteString("=")
-			b.WriteString(v)
-			b.WriteString("\n")
-		}
-		out = []byte(b.String())
-	default:
-		var err error
-		out, err = json.MarshalIndent(secrets, "", "  ")
-		if err != nil {
-			return fmt.Errorf("failed to marshal secrets: %w", err)
+	if opts.Keys != "" {
+		secrets = keyfilter.Apply(secrets, keyfilter.Split(opts.Keys))
+		if len(secrets) == 0 {
+			return fmt.Errorf("no keys match %s", opts.Keys)
 		}
 	}
 
-	if outputPath != "" {
-		dir := filepath.Dir(outputPath)
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			return fmt.Errorf("failed to create output directory: %w", err)
+	if err = rec.commit(); err != nil {
+		return err
+	}
+	if opts.Encrypt {
+		out, passphrase, err := sealBundle(secrets, environment, opts)
+		if err != nil {
+			return fmt.Errorf("failed to create bundle: %w", err)
 		}
-		if err := os.WriteFile(outputPath, out, 0600); err != nil {
-			return fmt.Errorf("failed to write output file: %w", err)
+		if err := writeExport(out, outputPath); err != nil {
+			return err
 		}
-		fmt.Printf("Exported to %s\n", outputPath)
-	} else {
-		fmt.Println(string(out))
+		if passphrase != "" {
+			fmt.Fprintf(os.Stderr, "One-time passphrase (send it separately from the bundle; it is not shown again):\n  %s\n", passphrase)
+		}
+		return nil
+	}
+
+	f, err := format.Lookup(formatName)
+	if err != nil {
+		return err
+	}
+	encOpts := format.Options{Name: exportResourceName(environment)}
+	if config.Current().Export.IncludeTimestamp && !opts.NoHeader {
+		encOpts.Header = exportHeader(vaultService, environment)
+	}
+	out, err := f.Encode(secrets, encOpts)
+	if err != nil {
+		return fmt.Errorf("failed to encode secrets as %s: %w", f.Name(), err)
 	}
+	defer zeroBytes(out)
+	return writeExport(out, outputPath)
+}
+
+func writeExport(out []byte, outputPath string) error {
+	if outputPath == "" {
+		os.Stdout.Write(out)
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	if err := os.WriteFile(outputPath, out, 0600); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	fmt.Printf("Exported to %s\n", outputPath)
 	return nil
 }
 
+func exportHeader(vaultService vault.Service, environment string) *format.Header {
+	c := config.Current()
+	if environment == "" {
+		environment = c.Project.DefaultEnv
+	}
+	fingerprint, err := vaultService.Fingerprint()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not fingerprint vault: %v\n", err)
+	}
+	return &format.Header{
+		ExportedAt:  time.Now(),
+		Environment: environment,
+		Project:     c.Project.Name,
+		Fingerprint: fingerprint,
+	}
+}
+
+// exportResourceName names manifest exports such as a Kubernetes Secret:
+// "<project>-<env>", or just the environment outside a named project.
+func exportResourceName(environment string) string {
+	c := config.Current()
+	if environment == "" {
+		environment = c.Project.DefaultEnv
+	}
+	if c.Project.Name != "" {
+		return c.Project.Name + "-" + environment
+	}
+	return environment
+}
+
 func (h *handlers) handleChangePassword(currentPassword, newPassword []byte, environment string) (err error) {
 	defer zeroBytes(currentPassword)
 	defer zeroBytes(newPassword)
 	vaultPath, _, _ := vault.GetVaultPath(environment)
-	defer func() { auditLog(audit.ActionChangePassword, vaultPath, environment, "", err) }()
+	rec := newAuditRecord(audit.ActionChangePassword, vaultPath, environment, "")
+	defer func() { rec.done(err) }()
+	if len(currentPassword) == 0 {
+		return fmt.Errorf("the current password is required to change it")
+	}
 	vaultService, err := h.getVaultService(environment)
 	if err != nil {
 		return fmt.Errorf("failed to resolve vault: %w", err)
@@ -308,6 +902,9 @@ func (h *handlers) handleChangePassword(currentPassword, newPassword []byte, env
 		return fmt.Errorf("failed to load vault (wrong password?): %w", err)
 	}
 
+	if err = rec.commit(); err != nil {
+		return err
+	}
 	if err = vaultService.Save(secrets, newPassword); err != nil {
 		return fmt.Errorf("failed to save vault with new password: %w", err)
 	}
@@ -362,7 +959,8 @@ func (h *handlers) handleStats(password []byte, environment string) (err error)
 func (h *handlers) handleCreateShares(parts, threshold int, outputDir string, password []byte, environment string) (err error) {
 	defer zeroBytes(password)
 	vaultPath, _, _ := vault.GetVaultPath(environment)
-	defer func() { auditLog(audit.ActionCreateShares, vaultPath, environment, outputDir, err) }()
+	rec := newAuditRecord(audit.ActionCreateShares, vaultPath, environment, outputDir)
+	defer func() { rec.done(err) }()
 
 	if parts < 2 || parts > 255 {
 		return fmt.Errorf("parts must be between 2 and 255, got %d", parts)
@@ -371,6 +969,9 @@ func (h *handlers) handleCreateShares(parts, threshold int, outputDir string, pa
 		return fmt.Errorf("threshold must be between 2 and parts (%d), got %d", parts, threshold)
 	}
 
+	if err = rec.commit(); err != nil {
+		return err
+	}
 	shares, err := shamir.SplitSecret(password, parts, threshold)
 	if err != nil {
 		return fmt.Errorf("failed to split secret: %w", err)
@@ -390,7 +991,8 @@ func (h *handlers) handleCreateShares(parts, threshold int, outputDir string, pa
 
 func (h *handlers) handleRecover(sharePaths []string, environment string) (err error) {
 	vaultPath, _, _ := vault.GetVaultPath(environment)
-	defer func() { auditLog(audit.ActionRecover, vaultPath, environment, "", err) }()
+	rec := newAuditRecord(audit.ActionRecover, vaultPath, environment, "")
+	defer func() { rec.done(err) }()
 
 	if len(sharePaths) < 2 {
 		return fmt.Errorf("at least 2 share files required")
@@ -412,6 +1014,9 @@ func (h *handlers) handleRecover(sharePaths []string, environment string) (err e
 	}
 	defer zeroBytes(recovered)
 
+	if err = rec.commit(); err != nil {
+		return err
+	}
 	fmt.Println(string(recovered))
 	return nil
 }
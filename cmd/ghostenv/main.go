@@ -1,11 +1,21 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"runtime"
+	"strings"
+	"time"
 
+	"github.com/SrPlugin/GhostEnv/internal/agent"
+	"github.com/SrPlugin/GhostEnv/internal/bundle"
+	"github.com/SrPlugin/GhostEnv/internal/cipher"
+	"github.com/SrPlugin/GhostEnv/internal/config"
+	"github.com/SrPlugin/GhostEnv/internal/format"
+	"github.com/SrPlugin/GhostEnv/internal/generate"
 	"github.com/SrPlugin/GhostEnv/internal/injector"
+	"github.com/SrPlugin/GhostEnv/internal/keyfilter"
 	"github.com/SrPlugin/GhostEnv/internal/version"
 	"github.com/spf13/cobra"
 )
@@ -13,9 +23,13 @@ import (
 var (
 	masterPassword string
 	environment    string
+	projectName    string
 )
 
 func main() {
+	if sock := os.Getenv(agent.SocketEnv); sock != "" {
+		cipher.SetKeyCache(agent.NewClient(sock))
+	}
 	runner := injector.NewRunner()
 	h := newHandlers(runner)
 
@@ -25,21 +39,61 @@ func main() {
 		Long:  "GhostEnv - Securely encrypt and inject environment variables.\nDeveloped by Sebastian Cheikh",
 	}
 
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		if isCobraCommand(cmd) {
+			return nil
+		}
+		return auditPreflight(environment)
+	}
+	// Commands that open no vault skip the preflight, so that a broken config
+	// or audit sink does not stop them.
+	noPreflight := func(cmd *cobra.Command, args []string) error { return nil }
 	rootCmd.PersistentFlags().StringVarP(&masterPassword, "pass", "p", "", "Master password (prefer GHOSTENV_PASS env to avoid visibility in process list)")
 	rootCmd.PersistentFlags().StringVarP(&environment, "env", "e", "", "Environment name (default: dev, uses global vault if not in project)")
+	rootCmd.PersistentFlags().StringVar(&projectName, "project", "", "Project of a multi-project .ghostenv.yml (default: $"+config.ProjectEnv+", else the one around the current directory)")
+	cobra.OnInitialize(func() { config.SelectProject(projectName) })
 
+	var setGenerate bool
+	var setShow bool
+	var setFromFile string
+	var setAllowReserved bool
+	var setGenOpts generate.Options
 	var setCmd = &cobra.Command{
-		Use:  "set [KEY] [VALUE]",
-		Args: cobra.ExactArgs(2),
+		Use:   "set [KEY] [VALUE|-]",
+		Short: "Store a secret (VALUE, '-' for stdin, --from-file, --generate, or prompt)",
+		Args:  cobra.RangeArgs(1, 2),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if setGenerate {
+				if len(args) == 2 || setFromFile != "" {
+					return fmt.Errorf("--generate cannot be combined with a value or --from-file")
+				}
+				pw, err := getPassword(masterPassword)
+				if err != nil {
+					return fmt.Errorf("password error: %w", err)
+				}
+				return h.handleSetGenerated(args[0], setGenOpts, setShow, pw, environment, setAllowReserved)
+			}
+			value, err := readSetValue(args[0], args[1:], setFromFile)
+			if err != nil {
+				return err
+			}
 			pw, err := getPassword(masterPassword)
 			if err != nil {
+				zeroBytes(value)
 				return fmt.Errorf("password error: %w", err)
 			}
-			return h.handleSet(args[0], args[1], pw, environment)
+			return h.handleSet(args[0], value, pw, environment, setAllowReserved)
 		},
 	}
+	setCmd.Flags().StringVar(&setFromFile, "from-file", "", "Read the value from a file (multi-line and binary safe)")
+	setCmd.Flags().BoolVarP(&setGenerate, "generate", "g", false, "Generate a random value instead of passing one")
+	setCmd.Flags().StringVar(&setGenOpts.Type, "type", generate.TypeAlnum, "Generated value type: "+strings.Join(generate.Types(), ", "))
+	setCmd.Flags().IntVar(&setGenOpts.Length, "length", 0, "Characters (alnum), bytes (hex, base64) or words (passphrase)")
+	setCmd.Flags().StringVar(&setGenOpts.Charset, "charset", "", "Characters to draw from for alnum (repeats are ignored)")
+	setCmd.Flags().BoolVar(&setShow, "show", false, "Print the generated value once")
+	setCmd.Flags().BoolVar(&setAllowReserved, "allow-reserved", false, "Allow reserved names such as PATH, HOME or LD_PRELOAD")
 
+	var runOpts runOptions
 	var runCmd = &cobra.Command{
 		Use:  "run -- [command]",
 		Args: cobra.MinimumNArgs(1),
@@ -48,7 +102,24 @@ func main() {
 			if err != nil {
 				return fmt.Errorf("password error: %w", err)
 			}
-			return h.handleRun(args[0], args[1:], pw, environment)
+			return h.handleRun(args[0], args[1:], pw, environment, runOpts)
+		},
+	}
+	runCmd.Flags().StringArrayVarP(&runOpts.Templates, "template", "t", nil, "Render a template before starting the command, as input:output (removed on exit; repeatable)")
+	runCmd.Flags().BoolVarP(&runOpts.Watch, "watch", "w", false, "Restart the command when the vault changes")
+	runCmd.Flags().BoolVar(&runOpts.Strict, "strict", false, "Refuse to start when a key declared in the schema is missing or malformed")
+	runCmd.Flags().DurationVar(&runOpts.GracePeriod, "grace-period", 10*time.Second, "Time to wait after SIGTERM before killing the command on restart or shutdown")
+
+	var checkCmd = &cobra.Command{
+		Use:   "check",
+		Short: "Validate the vault against the schema declared in .ghostenv.yml",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			pw, err := getPassword(masterPassword)
+			if err != nil {
+				return fmt.Errorf("password error: %w", err)
+			}
+			return h.handleCheck(pw, environment)
 		},
 	}
 
@@ -64,6 +135,7 @@ func main() {
 		},
 	}
 
+	var getDecode bool
 	var getCmd = &cobra.Command{
 		Use:   "get [KEY]",
 		Short: "Show the value of a specific secret",
@@ -73,9 +145,10 @@ func main() {
 			if err != nil {
 				return fmt.Errorf("password error: %w", err)
 			}
-			return h.handleGet(args[0], pw, environment)
+			return h.handleGet(args[0], getDecode, pw, environment)
 		},
 	}
+	getCmd.Flags().BoolVar(&getDecode, "decode", false, "Write only the raw value to stdout (decodes binary values)")
 
 	var removeCmd = &cobra.Command{
 		Use:   "remove [KEY]",
@@ -90,38 +163,51 @@ func main() {
 		},
 	}
 
+	var importOpts importOptions
 	var importCmd = &cobra.Command{
 		Use:   "import [FILE_PATH]",
-		Short: "Import secrets from a .env file",
+		Short: "Import secrets from a .env, JSON, YAML, TOML, shell, Docker, Kubernetes or GitHub Actions file",
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			pw, err := getPassword(masterPassword)
 			if err != nil {
 				return fmt.Errorf("password error: %w", err)
 			}
-			return h.handleImport(args[0], pw, environment)
+			return h.handleImport(args[0], importOpts, pw, environment)
 		},
 	}
+	importCmd.Flags().StringVarP(&importOpts.Format, "format", "f", "", "Input format: "+strings.Join(format.Names(), ", ")+" (default: from file extension, else env)")
+	importCmd.Flags().BoolVar(&importOpts.DryRun, "dry-run", false, "Show what would change without saving")
+	importCmd.Flags().BoolVar(&importOpts.NoOverwrite, "no-overwrite", false, "Keep existing values for keys already in the vault")
+	importCmd.Flags().BoolVar(&importOpts.Prune, "prune", false, "Delete vault keys that are not in the file")
+	importCmd.Flags().BoolVar(&importOpts.AllowReserved, "allow-reserved", false, "Import reserved names such as PATH, HOME or LD_PRELOAD instead of skipping them")
+	importCmd.Flags().BoolVar(&importOpts.Bundle, "bundle", false, "Read an encrypted bundle created with export --encrypt")
+	importCmd.Flags().StringVar(&importOpts.Identity, "identity", "", "Private key for public-key bundles (default ~/.ghostenv/"+bundle.IdentityFileName+")")
 
-	var exportFormat string
-	var exportOutput string
+	var exportOpts exportOptions
 	var exportCmd = &cobra.Command{
 		Use:   "export",
-		Short: "Export secrets in JSON or .env format",
+		Short: "Export secrets as JSON, YAML, TOML, .env, shell, Docker, Kubernetes or GitHub Actions",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			pw, err := getPassword(masterPassword)
 			if err != nil {
 				return fmt.Errorf("password error: %w", err)
 			}
-			return h.handleExport(pw, environment, exportFormat, exportOutput)
+			return h.handleExport(pw, environment, exportOpts)
 		},
 	}
-	exportCmd.Flags().StringVarP(&exportFormat, "format", "f", "", "Output format: json or env (default from config or json)")
-	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "Write to file instead of stdout")
+	exportCmd.Flags().StringVarP(&exportOpts.Format, "format", "f", "", "Output format: "+strings.Join(format.Names(), ", ")+" (default: from --output extension, else config or json)")
+	exportCmd.Flags().StringVarP(&exportOpts.Output, "output", "o", "", "Write to file instead of stdout")
+	exportCmd.Flags().StringVar(&exportOpts.Keys, "keys", "", "Only export matching keys (comma-separated, globs allowed)")
+	exportCmd.Flags().BoolVar(&exportOpts.Encrypt, "encrypt", false, "Write an encrypted bundle (one-time passphrase, or --recipient)")
+	exportCmd.Flags().StringVar(&exportOpts.Recipient, "recipient", "", "Encrypt the bundle to this public key (or a file containing it) instead of a passphrase")
+	exportCmd.Flags().DurationVar(&exportOpts.Expires, "expires", 0, "Refuse to import the bundle after this long (e.g. 24h)")
+	exportCmd.Flags().BoolVar(&exportOpts.NoHeader, "no-header", false, "Omit the export header even when export.include_timestamp is set")
 
 	var versionCmd = &cobra.Command{
-		Use:   "version",
-		Short: "Print version and build information",
+		Use:               "version",
+		Short:             "Print version and build information",
+		PersistentPreRunE: noPreflight,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			fmt.Printf("ghostenv version %s\n", version.Version)
 			if version.BuildDate != "" {
@@ -139,7 +225,8 @@ func main() {
 		Use:   "change-password",
 		Short: "Change the master password for the vault",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			currentPw, err := getPassword(masterPassword)
+			// Never from the agent: re-keying takes knowing the password.
+			currentPw, err := getMasterPassword(masterPassword)
 			if err != nil {
 				return fmt.Errorf("password error: %w", err)
 			}
@@ -176,7 +263,7 @@ func main() {
 		Short: "Split master password into Shamir secret shares",
 		Long:  "Prompts for the master password and splits it into N shares; K shares are required to recover it (K-of-N).",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			pw, err := getPassword(masterPassword)
+			pw, err := getMasterPassword(masterPassword)
 			if err != nil {
 				return fmt.Errorf("password error: %w", err)
 			}
@@ -198,9 +285,427 @@ func main() {
 		},
 	}
 
-	rootCmd.AddCommand(setCmd, runCmd, listCmd, getCmd, removeCmd, importCmd, exportCmd, versionCmd, changePasswordCmd, statsCmd, createSharesCmd, recoverCmd)
-	if err := rootCmd.Execute(); err != nil {
+	var renderTemplate string
+	var renderOutput string
+	var renderCmd = &cobra.Command{
+		Use:   "render",
+		Short: "Render a Go text/template with secrets",
+		Long:  "Renders a text/template file with the decrypted secrets as data (e.g. {{ .API_KEY }}).\nHelpers: b64enc, json, default, required. Referencing a missing key fails the render.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			pw, err := getPassword(masterPassword)
+			if err != nil {
+				return fmt.Errorf("password error: %w", err)
+			}
+			return h.handleRender(renderTemplate, renderOutput, pw, environment)
+		},
+	}
+	renderCmd.Flags().StringVarP(&renderTemplate, "template", "t", "", "Template file to render (required)")
+	renderCmd.Flags().StringVarP(&renderOutput, "output", "o", "", "Write to file instead of stdout")
+	renderCmd.MarkFlagRequired("template")
+
+	var upProcfile string
+	var upOnly string
+	var upGrace time.Duration
+	var upCmd = &cobra.Command{
+		Use:   "up",
+		Short: "Start all processes from a Procfile with one unlock",
+		Long:  "Starts every process from a Procfile or the processes section of .ghostenv.yml, injecting secrets filtered per process.\nOutput is prefixed with the process name. If any process exits, all of them are stopped.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			pw, err := getPassword(masterPassword)
+			if err != nil {
+				return fmt.Errorf("password error: %w", err)
+			}
+			return h.handleUp(upProcfile, keyfilter.Split(upOnly), upGrace, pw, environment)
+		},
+	}
+	upCmd.Flags().StringVarP(&upProcfile, "procfile", "f", "", "Procfile to read (default: Procfile in project root, unless .ghostenv.yml defines processes)")
+	upCmd.Flags().StringVar(&upOnly, "only", "", "Comma-separated process names or patterns to start")
+	upCmd.Flags().DurationVar(&upGrace, "grace-period", 10*time.Second, "Time to wait after SIGTERM before killing processes on shutdown")
+
+	var agentSocket string
+	var agentTTL time.Duration
+	var agentForeground bool
+	var agentCmd = &cobra.Command{
+		Use:               "agent",
+		Short:             "Cache derived vault keys in a local agent",
+		Long:              "Runs a local agent that keeps derived vault keys in locked memory behind a Unix socket.\nCommands use it automatically when " + agent.SocketEnv + " is set.",
+		PersistentPreRunE: noPreflight,
+	}
+	agentCmd.PersistentFlags().StringVar(&agentSocket, "socket", "", "Agent socket path (default: $"+agent.SocketEnv+" or a per-user runtime path)")
+	var agentStartCmd = &cobra.Command{
+		Use:   "start",
+		Short: "Start the agent and print the environment variable to use it",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return h.handleAgentStart(agentSocket, agentTTL, agentForeground)
+		},
+	}
+	agentStartCmd.Flags().DurationVar(&agentTTL, "ttl", agent.DefaultTTL, "Evict keys not used for this long (0 keeps them until locked)")
+	agentStartCmd.Flags().BoolVar(&agentForeground, "foreground", false, "Run in the foreground instead of detaching")
+	var agentLockCmd = &cobra.Command{
+		Use:   "lock",
+		Short: "Wipe all keys held by the agent",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return h.handleAgentLock(agentSocket)
+		},
+	}
+	var agentStopCmd = &cobra.Command{
+		Use:   "stop",
+		Short: "Wipe all keys and stop the agent",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return h.handleAgentStop(agentSocket)
+		},
+	}
+	var agentStatusCmd = &cobra.Command{
+		Use:   "status",
+		Short: "Show whether the agent is running and how many keys it holds",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return h.handleAgentStatus(agentSocket)
+		},
+	}
+	agentCmd.AddCommand(agentStartCmd, agentLockCmd, agentStopCmd, agentStatusCmd)
+
+	var serveOpts serveOptions
+	var serveCmd = &cobra.Command{
+		Use:   "serve",
+		Short: "Serve secrets to local clients over HTTP(S)",
+		Long:  "Starts a local secrets API (microservices.server) for sidecars:\n  GET /healthz\n  GET /v1/secrets/{KEY}\n  GET /v1/secrets?pattern=DB_*\nRequests need a bearer token from 'serve token create', scoped to an environment and key patterns.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			pw, err := getPassword(masterPassword)
+			if err != nil {
+				return fmt.Errorf("password error: %w", err)
+			}
+			return h.handleServe(serveOpts, pw, environment)
+		},
+	}
+	serveCmd.Flags().StringVar(&serveOpts.Environments, "envs", "", "Comma-separated environments to serve (default: --env)")
+	serveCmd.Flags().StringVar(&serveOpts.Host, "host", "", "Listen host (default from config, 127.0.0.1)")
+	serveCmd.Flags().IntVar(&serveOpts.Port, "port", 0, "Listen port (default from config, 8080)")
+	serveCmd.Flags().IntVar(&serveOpts.RateLimit, "rate-limit", 0, "Requests per minute per client (default from config, 120)")
+
+	var tokenCmd = &cobra.Command{
+		Use:   "token",
+		Short: "Manage bearer tokens for serve",
+	}
+	var tokenKeys string
+	var tokenCreateCmd = &cobra.Command{
+		Use:   "create [NAME]",
+		Short: "Create a token for --env limited to --keys",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return h.handleTokenCreate(args[0], tokenKeys, environment)
+		},
+	}
+	tokenCreateCmd.Flags().StringVar(&tokenKeys, "keys", "", "Comma-separated key patterns the token may read (default: all)")
+	var tokenListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List tokens",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return h.handleTokenList(environment)
+		},
+	}
+	var tokenRevokeCmd = &cobra.Command{
+		Use:   "revoke [NAME]",
+		Short: "Revoke a token",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return h.handleTokenRevoke(args[0], environment)
+		},
+	}
+	tokenCmd.AddCommand(tokenCreateCmd, tokenListCmd, tokenRevokeCmd)
+	serveCmd.AddCommand(tokenCmd)
+
+	var dbCmd = &cobra.Command{
+		Use:   "db",
+		Short: "Postgres helpers using microservices.postgres credentials",
+	}
+	var dbURLCmd = &cobra.Command{
+		Use:   "url",
+		Short: "Print the connection URL with the password masked",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			pw, err := getPassword(masterPassword)
+			if err != nil {
+				return fmt.Errorf("password error: %w", err)
+			}
+			return h.handleDBURL(pw, environment)
+		},
+	}
+	var dbPsqlCmd = &cobra.Command{
+		Use:   "psql [-- psql args]",
+		Short: "Launch psql with credentials injected via PG* variables",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			pw, err := getPassword(masterPassword)
+			if err != nil {
+				return fmt.Errorf("password error: %w", err)
+			}
+			return h.handleDBPsql(args, pw, environment)
+		},
+	}
+	dbCmd.AddCommand(dbURLCmd, dbPsqlCmd)
+
+	var rotateOpts rotateOptions
+	var rotateCmd = &cobra.Command{
+		Use:   "rotate [KEY]",
+		Short: "Generate a new value, apply it with a rotator, then store it",
+		Long:  "Generates a strong random value and runs the rotator configured under rotation.KEY (or --command).\nThe vault is updated only if the rotator succeeds; the previous value is kept in history.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			pw, err := getPassword(masterPassword)
+			if err != nil {
+				return fmt.Errorf("password error: %w", err)
+			}
+			return h.handleRotate(args[0], rotateOpts, pw, environment)
+		},
+	}
+	rotateCmd.Flags().StringVar(&rotateOpts.Command, "command", "", "Shell command that applies $GHOSTENV_NEW_VALUE (overrides config)")
+	rotateCmd.Flags().IntVar(&rotateOpts.Length, "length", 0, "Length of the generated value (default 32)")
+	rotateCmd.Flags().DurationVar(&rotateOpts.Timeout, "timeout", 0, "Maximum time for the rotator (default 60s)")
+
+	var diffOpts diffOptions
+	var diffCmd = &cobra.Command{
+		Use:   "diff",
+		Short: "Compare two environments, or an environment against a file (exit status 1 if they differ)",
+		Example: `  ghostenv diff --from staging --to production
+  ghostenv diff --against .env.example --by keys`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if diffOpts.From == "" {
+				diffOpts.From = environment
+			}
+			differ, err := h.handleDiff(diffOpts)
+			if err != nil {
+				return err
+			}
+			if differ {
+				// Not a failure to report, only the exit status.
+				cmd.SilenceErrors = true
+				cmd.SilenceUsage = true
+				return errSecretsDiffer
+			}
+			return nil
+		},
+	}
+	diffCmd.Flags().StringVar(&diffOpts.From, "from", "", "Environment to compare from (default: --env or the default environment)")
+	diffCmd.Flags().StringVar(&diffOpts.To, "to", "", "Environment to compare to")
+	diffCmd.Flags().StringVar(&diffOpts.Against, "against", "", "File to compare to (any import format, e.g. .env.example)")
+	diffCmd.Flags().StringVar(&diffOpts.By, "by", "hash", "What counts as a change: hash (any value change), length, or keys (presence only)")
+	diffCmd.Flags().BoolVar(&diffOpts.ShowValues, "show-values", false, "Print the differing values (they are hidden by default)")
+	diffCmd.Flags().StringVar(&diffOpts.FromPass, "from-pass", "", "Password for the --from vault (or "+fromPassEnv+")")
+	diffCmd.Flags().StringVar(&diffOpts.ToPass, "to-pass", "", "Password for the --to vault (or "+toPassEnv+")")
+
+	var copyOpts copyOptions
+	var copyCmd = &cobra.Command{
+		Use:   "copy [KEYS...]",
+		Short: "Copy secrets from one environment to another",
+		Example: `  ghostenv copy --from dev --to staging API_KEY DB_URL
+  ghostenv copy --from dev --to staging --pattern 'STRIPE_*' --overwrite`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if copyOpts.From == "" {
+				copyOpts.From = environment
+			}
+			return h.handleCopy(args, copyOpts)
+		},
+	}
+	copyCmd.Flags().StringVar(&copyOpts.From, "from", "", "Source environment (default: --env or the default environment)")
+	copyCmd.Flags().StringVar(&copyOpts.To, "to", "", "Target environment")
+	copyCmd.Flags().StringVar(&copyOpts.Pattern, "pattern", "", "Copy keys matching these globs (comma-separated)")
+	copyCmd.Flags().BoolVar(&copyOpts.Overwrite, "overwrite", false, "Replace target values that differ (old values go to history)")
+	copyCmd.Flags().BoolVar(&copyOpts.DryRun, "dry-run", false, "Show what would be copied without saving")
+	copyCmd.Flags().BoolVar(&copyOpts.AllowReserved, "allow-reserved", false, "Allow reserved names such as PATH, HOME or LD_PRELOAD")
+	copyCmd.Flags().StringVar(&copyOpts.FromPass, "from-pass", "", "Password for the source vault (or "+fromPassEnv+")")
+	copyCmd.Flags().StringVar(&copyOpts.ToPass, "to-pass", "", "Password for the target vault (or "+toPassEnv+")")
+
+	var promoteOpts promoteOptions
+	var promoteCmd = &cobra.Command{
+		Use:   "promote",
+		Short: "Review and apply the differences between two environments",
+		Example: `  ghostenv promote --from staging --to production
+  ghostenv promote --from staging --to production --out promote.plan
+  ghostenv promote --plan promote.plan`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if promoteOpts.From == "" && promoteOpts.Plan == "" {
+				promoteOpts.From = environment
+			}
+			return h.handlePromote(promoteOpts)
+		},
+	}
+	promoteCmd.Flags().StringVar(&promoteOpts.From, "from", "", "Source environment (default: --env or the default environment)")
+	promoteCmd.Flags().StringVar(&promoteOpts.To, "to", "", "Target environment")
+	promoteCmd.Flags().StringVar(&promoteOpts.Pattern, "pattern", "", "Only promote keys matching these globs (comma-separated)")
+	promoteCmd.Flags().BoolVar(&promoteOpts.Prune, "prune", false, "Also delete keys that exist only in the target")
+	promoteCmd.Flags().BoolVarP(&promoteOpts.Yes, "yes", "y", false, "Apply without asking for confirmation")
+	promoteCmd.Flags().StringVar(&promoteOpts.Out, "out", "", "Write a reviewed plan to this file instead of applying")
+	promoteCmd.Flags().StringVar(&promoteOpts.Plan, "plan", "", "Apply a plan written with --out (refused if either vault changed since)")
+	promoteCmd.Flags().BoolVar(&promoteOpts.AllowReserved, "allow-reserved", false, "Allow reserved names such as PATH, HOME or LD_PRELOAD")
+	promoteCmd.Flags().StringVar(&promoteOpts.FromPass, "from-pass", "", "Password for the source vault (or "+fromPassEnv+")")
+	promoteCmd.Flags().StringVar(&promoteOpts.ToPass, "to-pass", "", "Password for the target vault (or "+toPassEnv+")")
+
+	var bundleCmd = &cobra.Command{
+		Use:               "bundle",
+		Short:             "Manage encrypted export bundles",
+		PersistentPreRunE: noPreflight,
+	}
+	var keygenOutput string
+	var bundleKeygenCmd = &cobra.Command{
+		Use:   "keygen",
+		Short: "Create a key pair for receiving bundles; prints the public key",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return h.handleBundleKeygen(keygenOutput)
+		},
+	}
+	bundleKeygenCmd.Flags().StringVarP(&keygenOutput, "output", "o", "", "Where to write the private key (default ~/.ghostenv/"+bundle.IdentityFileName+")")
+	var bundleInspectCmd = &cobra.Command{
+		Use:   "inspect [FILE]",
+		Short: "Show a bundle's keys, expiry and origin without decrypting it",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return h.handleBundleInspect(args[0])
+		},
+	}
+	bundleCmd.AddCommand(bundleKeygenCmd, bundleInspectCmd)
+
+	var auditCmd = &cobra.Command{
+		Use:   "audit",
+		Short: "Inspect and verify the audit log",
+	}
+	var auditFile string
+	auditCmd.PersistentFlags().StringVar(&auditFile, "file", "", "Audit log to read (default: the log for --env's vault)")
+	var auditVerifyCmd = &cobra.Command{
+		Use:               "verify",
+		Short:             "Check the audit log's hash chain and signatures for gaps, reordering or edits",
+		Args:              cobra.NoArgs,
+		PersistentPreRunE: noPreflight,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return h.handleAuditVerify(auditFile, environment)
+		},
+	}
+	var auditQ auditQuery
+	var auditSuccess bool
+	addAuditFilters := func(c *cobra.Command) {
+		c.Flags().StringVar(&auditQ.Actions, "action", "", "Only these actions (comma-separated, e.g. get,export)")
+		c.Flags().StringVar(&auditQ.Keys, "key", "", "Only these keys (comma-separated globs)")
+		c.Flags().StringVar(&auditQ.Environment, "environment", "", "Only entries for this environment")
+		c.Flags().StringVar(&auditQ.User, "user", "", "Only entries by this OS user")
+		c.Flags().StringVar(&auditQ.Since, "since", "", "Only entries after this time (RFC 3339, YYYY-MM-DD, or an age like 24h or 7d)")
+		c.Flags().StringVar(&auditQ.Until, "until", "", "Only entries before this time (same forms as --since)")
+		c.Flags().BoolVar(&auditSuccess, "success", false, "Only successful (--success) or failed (--success=false) entries")
+	}
+	var auditFormat string
+	var auditShowCmd = &cobra.Command{
+		Use:               "show",
+		Short:             "List audit entries, including rotated files",
+		Args:              cobra.NoArgs,
+		PersistentPreRunE: noPreflight,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			auditQ.File = auditFile
+			auditQ.Success = successFlag(cmd.Flags().Changed("success"), auditSuccess)
+			return h.handleAuditShow(auditQ, auditFormat, environment)
+		},
+	}
+	addAuditFilters(auditShowCmd)
+	auditShowCmd.Flags().StringVarP(&auditFormat, "format", "f", "table", "Output format: table, json or csv")
+	var spikeWindow time.Duration
+	var spikeThreshold int
+	var auditSummaryCmd = &cobra.Command{
+		Use:               "summary",
+		Short:             "Counts per action and user, failed-authentication spikes and last access per key",
+		Args:              cobra.NoArgs,
+		PersistentPreRunE: noPreflight,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			auditQ.File = auditFile
+			auditQ.Success = successFlag(cmd.Flags().Changed("success"), auditSuccess)
+			return h.handleAuditSummary(auditQ, spikeWindow, spikeThreshold, environment)
+		},
+	}
+	addAuditFilters(auditSummaryCmd)
+	auditSummaryCmd.Flags().DurationVar(&spikeWindow, "spike-window", 10*time.Minute, "Window for counting failed authentication attempts")
+	auditSummaryCmd.Flags().IntVar(&spikeThreshold, "spike-threshold", 5, "Failures within --spike-window that count as a spike")
+	var auditUnmaskCmd = &cobra.Command{
+		Use:   "unmask [hmac:HASH...]",
+		Short: "Map masked key hashes in the audit log back to key names (needs the vault password)",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			auditQ.File = auditFile
+			auditQ.Success = successFlag(cmd.Flags().Changed("success"), auditSuccess)
+			pw, err := getPassword(masterPassword)
+			if err != nil {
+				return fmt.Errorf("password error: %w", err)
+			}
+			return h.handleAuditUnmask(pw, args, auditQ, environment)
+		},
+	}
+	addAuditFilters(auditUnmaskCmd)
+	auditCmd.AddCommand(auditVerifyCmd, auditShowCmd, auditSummaryCmd, auditUnmaskCmd)
+
+	var configCmd = &cobra.Command{
+		Use:   "config",
+		Short: "Inspect and validate .ghostenv.yml and the global config",
+		// Runs without the config check of the other commands, so that an
+		// invalid config can be examined.
+		PersistentPreRunE: noPreflight,
+	}
+	var configShowCmd = &cobra.Command{
+		Use:   "show",
+		Short: "Print the effective config, noting the file and line (or default) behind each value",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return h.handleConfigShow()
+		},
+	}
+	var configValidateCmd = &cobra.Command{
+		Use:   "validate",
+		Short: "Check the config files for unknown fields and invalid values",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return h.handleConfigValidate()
+		},
+	}
+	configCmd.AddCommand(configShowCmd, configValidateCmd)
+
+	var whereCmd = &cobra.Command{
+		Use:   "where",
+		Short: "Show the vault file for --env and how its path was resolved",
+		Args:  cobra.NoArgs,
+		// Skips the audit preflight, which would create the vault directory
+		// before it can be explained.
+		PersistentPreRunE: noPreflight,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return h.handleWhere(environment)
+		},
+	}
+
+	var listProjectsCmd = &cobra.Command{
+		Use:   "list-projects",
+		Short: "List the projects of a multi-project .ghostenv.yml (* marks the current one)",
+		Args:  cobra.NoArgs,
+		// Works without a selected project, like config.
+		PersistentPreRunE: noPreflight,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return h.handleListProjects()
+		},
+	}
+
+	rootCmd.AddCommand(setCmd, runCmd, listCmd, getCmd, removeCmd, importCmd, exportCmd, versionCmd, changePasswordCmd, statsCmd, createSharesCmd, recoverCmd, renderCmd, upCmd, agentCmd, serveCmd, dbCmd, rotateCmd, bundleCmd, diffCmd, copyCmd, promoteCmd, checkCmd, auditCmd, configCmd, listProjectsCmd, whereCmd)
+	err := rootCmd.Execute()
+	differ := errors.Is(err, errSecretsDiffer)
+	if err != nil && !differ {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
+	if err := auditFailure(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: operation could not be audited: %v\n", err)
+		os.Exit(1)
+	}
+	if differ {
+		os.Exit(1)
+	}
+}
+
+// isCobraCommand reports cobra's own help and completion commands.
+func isCobraCommand(cmd *cobra.Command) bool {
+	switch cmd.Name() {
+	case "help", cobra.ShellCompRequestCmd, cobra.ShellCompNoDescRequestCmd:
+		return true
+	}
+	return cmd.HasParent() && cmd.Parent().Name() == "completion"
 }
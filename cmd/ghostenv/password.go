@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"unsafe"
@@ -14,6 +15,9 @@ func zeroBytes(b []byte) {
 	}
 }
 
+// getPassword returns the master password from GHOSTENV_PASS, the -p flag or
+// a prompt. When the agent already holds the keys for the selected vault it
+// returns an empty password and the cached keys are used instead.
 func getPassword(flagValue string) ([]byte, error) {
 	if env := os.Getenv("GHOSTENV_PASS"); env != "" {
 		return []byte(env), nil
@@ -21,8 +25,87 @@ func getPassword(flagValue string) ([]byte, error) {
 	if flagValue != "" {
 		return []byte(flagValue), nil
 	}
+	if agentUnlocked(environment) {
+		return []byte{}, nil
+	}
+	return promptPassword()
+}
+
+// getMasterPassword is getPassword for commands that need the password
+// itself rather than a vault key (e.g. create-shares), or that replace the
+// key (change-password). It never accepts the agent's keys.
+func getMasterPassword(flagValue string) ([]byte, error) {
+	if env := os.Getenv("GHOSTENV_PASS"); env != "" {
+		return []byte(env), nil
+	}
+	if flagValue != "" {
+		return []byte(flagValue), nil
+	}
+	return promptPassword()
+}
+
+// getEnvPassword is getPassword for commands that open several vaults, each
+// of which may have its own password (diff, copy). The side-specific source
+// (envVar, then flagValue) wins over GHOSTENV_PASS and -p; the prompt names
+// the environment.
+func getEnvPassword(env, flagValue, envVar string) ([]byte, error) {
+	if pw := suppliedEnvPassword(flagValue, envVar); pw != nil {
+		return pw, nil
+	}
+	if agentUnlocked(env) {
+		return []byte{}, nil
+	}
+	return promptPasswordLabel(fmt.Sprintf("Enter password for %s: ", env))
+}
+
+// getNewEnvPassword is getEnvPassword for a vault that does not exist yet
+// (the target of copy or promote). A prompted password is asked for twice,
+// since a typo would lock the new vault.
+func getNewEnvPassword(env, flagValue, envVar string) ([]byte, error) {
+	if pw := suppliedEnvPassword(flagValue, envVar); pw != nil {
+		return pw, nil
+	}
+	pw, err := promptPasswordLabel(fmt.Sprintf("Enter new password for %s: ", env))
+	if err != nil {
+		return nil, err
+	}
+	confirmPw, err := promptPasswordLabel(fmt.Sprintf("Confirm password for %s: ", env))
+	if err != nil {
+		zeroBytes(pw)
+		return nil, err
+	}
+	defer zeroBytes(confirmPw)
+	if !bytes.Equal(pw, confirmPw) {
+		zeroBytes(pw)
+		return nil, fmt.Errorf("passwords do not match")
+	}
+	return pw, nil
+}
+
+// suppliedEnvPassword returns the password for one side of diff or copy when
+// it was given without a prompt, or nil.
+func suppliedEnvPassword(flagValue, envVar string) []byte {
+	if v := os.Getenv(envVar); v != "" {
+		return []byte(v)
+	}
+	if flagValue != "" {
+		return []byte(flagValue)
+	}
+	if v := os.Getenv("GHOSTENV_PASS"); v != "" {
+		return []byte(v)
+	}
+	if masterPassword != "" {
+		return []byte(masterPassword)
+	}
+	return nil
+}
+
+func promptPassword() ([]byte, error) {
+	return promptPasswordLabel("Enter Master Password: ")
+}
 
-	fmt.Print("Enter Master Password: ")
+func promptPasswordLabel(label string) ([]byte, error) {
+	fmt.Print(label)
 	bytePassword, err := term.ReadPassword(int(os.Stdin.Fd()))
 	fmt.Println()
 	if err != nil {
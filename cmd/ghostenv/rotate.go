@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/SrPlugin/GhostEnv/internal/audit"
+	"github.com/SrPlugin/GhostEnv/internal/config"
+	"github.com/SrPlugin/GhostEnv/internal/generate"
+	"github.com/SrPlugin/GhostEnv/internal/postgres"
+	"github.com/SrPlugin/GhostEnv/internal/rotate"
+	"github.com/SrPlugin/GhostEnv/internal/storage"
+	"github.com/SrPlugin/GhostEnv/internal/vault"
+)
+
+type rotateOptions struct {
+	Command string
+	Length  int
+	Timeout time.Duration
+}
+
+func (h *handlers) handleRotate(key string, opts rotateOptions, password []byte, environment string) (err error) {
+	defer zeroBytes(password)
+	vaultPath, _, _ := vault.GetVaultPath(environment)
+	rec := newAuditRecord(audit.ActionRotate, vaultPath, environment, key)
+	defer func() { rec.done(err) }()
+	vaultService, err := h.getVaultService(environment)
+	if err != nil {
+		return fmt.Errorf("failed to resolve vault: %w", err)
+	}
+
+	secrets, err := vaultService.Load(password)
+	if err != nil {
+		if err == storage.ErrVaultNotFound {
+			return fmt.Errorf("vault not found")
+		}
+		return fmt.Errorf("failed to load vault: %w", err)
+	}
+	oldValue, ok := secrets[key]
+	if !ok {
+		return fmt.Errorf("secret '%s' not found", key)
+	}
+
+	cfg := config.Current()
+	var rc config.RotatorConfig
+	if cfg != nil {
+		rc = cfg.Rotation[key]
+	}
+	if opts.Command != "" {
+		rc = config.RotatorConfig{Type: rotate.TypeShell, Command: opts.Command, Length: rc.Length, Timeout: rc.Timeout}
+	}
+	if rc.Command == "" && rc.Type == "" {
+		return fmt.Errorf("no rotator configured for %s: add rotation.%s to %s or pass --command", key, key, config.ProjectConfigName)
+	}
+	rotator, err := rotate.New(rc)
+	if err != nil {
+		return err
+	}
+
+	length := opts.Length
+	if length == 0 {
+		length = rc.Length
+	}
+	if length == 0 {
+		length = rotate.DefaultLength
+	}
+	timeout := opts.Timeout
+	if timeout == 0 && rc.Timeout != "" {
+		if timeout, err = time.ParseDuration(rc.Timeout); err != nil {
+			return fmt.Errorf("invalid rotation.%s.timeout: %w", key, err)
+		}
+	}
+	if timeout == 0 {
+		timeout = rotate.DefaultTimeout
+	}
+
+	if length < rotate.MinLength {
+		return fmt.Errorf("length must be at least %d, got %d", rotate.MinLength, length)
+	}
+	// Alphanumerics are safe to splice into SQL and shell commands.
+	newValue, err := generate.Generate(generate.Options{Type: generate.TypeAlnum, Length: length})
+	if err != nil {
+		return fmt.Errorf("failed to generate value: %w", err)
+	}
+
+	req := rotate.Request{Key: key, OldValue: oldValue, NewValue: newValue}
+	if cfg != nil && cfg.Microservices.Postgres.Enabled {
+		if creds, credErr := postgres.FromConfig(cfg.Microservices.Postgres, secrets); credErr == nil {
+			req.Env = creds.Env()
+		}
+	}
+
+	if err = rec.commit(); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err = rotator.Rotate(ctx, req); err != nil {
+		return fmt.Errorf("rotator %s failed, vault unchanged: %w", rotator.Name(), err)
+	}
+
+	meta := vaultService.Metadata()
+	meta.PushHistory(key, oldValue, time.Now())
+	// The generated value is plain text even if the old one was binary.
+	meta.SetEncoding(key, "")
+	secrets[key] = newValue
+	if err = vaultService.Save(secrets, password); err != nil {
+		// The new value is already live; make sure it is not lost.
+		fmt.Fprintf(os.Stderr, "CRITICAL: %s was rotated but the vault could not be saved. New value:\n%s\n", key, newValue)
+		return fmt.Errorf("failed to save vault after rotation: %w", err)
+	}
+
+	fmt.Printf("Secret '%s' rotated (rotator: %s, previous value kept in history)\n", key, rotator.Name())
+	return nil
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SrPlugin/GhostEnv/internal/injector"
+	"github.com/SrPlugin/GhostEnv/internal/vault"
+)
+
+const testRotateConfig = `project:
+  name: rotatetest
+security:
+  argon2:
+    memory: 8MB
+    iterations: 1
+    parallelism: 1
+`
+
+// stubRotator writes a rotator script that records the values it was given
+// in dir and exits with status.
+func stubRotator(t *testing.T, dir string, status int) string {
+	t.Helper()
+	script := filepath.Join(dir, "rotator.sh")
+	body := "#!/bin/sh\n" +
+		"printf '%s' \"$GHOSTENV_ROTATE_KEY\" > \"$STUB_ROTATOR_OUT/key\"\n" +
+		"printf '%s' \"$GHOSTENV_OLD_VALUE\" > \"$STUB_ROTATOR_OUT/old\"\n" +
+		"printf '%s' \"$GHOSTENV_NEW_VALUE\" > \"$STUB_ROTATOR_OUT/new\"\n" +
+		"echo 'stub rotator refused' >&2\n" +
+		"exit " + strconv.Itoa(status) + "\n"
+	if err := os.WriteFile(script, []byte(body), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("STUB_ROTATOR_OUT", dir)
+	return script
+}
+
+func readStub(t *testing.T, dir, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRotateStoresValueAppliedByRotator(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("stub rotator is a shell script")
+	}
+	svc := testProject(t, testRotateConfig)
+	// A binary value the rotation replaces with plain text.
+	svc.Metadata().SetEncoding("API_TOKEN", vault.EncodingBase64)
+	if err := svc.Save(map[string]string{"API_TOKEN": "old-token", "OTHER": "kept"}, []byte("master")); err != nil {
+		t.Fatal(err)
+	}
+	out := t.TempDir()
+	script := stubRotator(t, out, 0)
+
+	h := newHandlers(injector.NewRunner())
+	opts := rotateOptions{Command: script, Length: 24, Timeout: 10 * time.Second}
+	if err := h.handleRotate("API_TOKEN", opts, []byte("master"), "development"); err != nil {
+		t.Fatalf("handleRotate: %v", err)
+	}
+
+	if got := readStub(t, out, "key"); got != "API_TOKEN" {
+		t.Errorf("rotator key = %q, want API_TOKEN", got)
+	}
+	if got := readStub(t, out, "old"); got != "old-token" {
+		t.Errorf("rotator old value = %q, want old-token", got)
+	}
+	applied := readStub(t, out, "new")
+	if len(applied) != 24 {
+		t.Errorf("rotator new value has length %d, want 24", len(applied))
+	}
+
+	secrets, err := svc.Load([]byte("master"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secrets["API_TOKEN"] != applied {
+		t.Errorf("vault holds %q, want the value the rotator applied", secrets["API_TOKEN"])
+	}
+	if secrets["OTHER"] != "kept" {
+		t.Errorf("OTHER = %q, want it untouched", secrets["OTHER"])
+	}
+	if enc := svc.Metadata().Encoding("API_TOKEN"); enc != "" {
+		t.Errorf("API_TOKEN encoding = %q, want plain text after rotation", enc)
+	}
+	history := svc.Metadata().History("API_TOKEN")
+	if len(history) != 1 || history[0].Value != "old-token" {
+		t.Errorf("history = %+v, want the previous value", history)
+	}
+}
+
+func TestRotateFailureLeavesVaultUnchanged(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("stub rotator is a shell script")
+	}
+	svc := testProject(t, testRotateConfig)
+	if err := svc.Save(map[string]string{"API_TOKEN": "old-token"}, []byte("master")); err != nil {
+		t.Fatal(err)
+	}
+	script := stubRotator(t, t.TempDir(), 3)
+
+	h := newHandlers(injector.NewRunner())
+	opts := rotateOptions{Command: script, Timeout: 10 * time.Second}
+	err := h.handleRotate("API_TOKEN", opts, []byte("master"), "development")
+	if err == nil || !strings.Contains(err.Error(), "vault unchanged") || !strings.Contains(err.Error(), "stub rotator refused") {
+		t.Fatalf("handleRotate error = %v, want the rotator failure with its stderr", err)
+	}
+
+	secrets, err := svc.Load([]byte("master"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secrets["API_TOKEN"] != "old-token" {
+		t.Errorf("API_TOKEN = %q, want old-token", secrets["API_TOKEN"])
+	}
+	if history := svc.Metadata().History("API_TOKEN"); len(history) != 0 {
+		t.Errorf("history = %+v, want none after a failed rotation", history)
+	}
+}
+
+func TestRemoveDropsRotationHistory(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("stub rotator is a shell script")
+	}
+	svc := testProject(t, testRotateConfig)
+	if err := svc.Save(map[string]string{"API_TOKEN": "old-token", "OTHER": "kept"}, []byte("master")); err != nil {
+		t.Fatal(err)
+	}
+	script := stubRotator(t, t.TempDir(), 0)
+
+	h := newHandlers(injector.NewRunner())
+	opts := rotateOptions{Command: script, Timeout: 10 * time.Second}
+	if err := h.handleRotate("API_TOKEN", opts, []byte("master"), "development"); err != nil {
+		t.Fatalf("handleRotate: %v", err)
+	}
+	if err := h.handleRemove("API_TOKEN", []byte("master"), "development"); err != nil {
+		t.Fatalf("handleRemove: %v", err)
+	}
+
+	secrets, err := svc.Load([]byte("master"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := secrets["API_TOKEN"]; ok {
+		t.Error("API_TOKEN is still in the vault")
+	}
+	for name := range svc.Metadata() {
+		if strings.HasPrefix(name, "history:") {
+			t.Errorf("metadata %q outlived the removed key", name)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/SrPlugin/GhostEnv/internal/audit"
+	"github.com/SrPlugin/GhostEnv/internal/config"
+	"github.com/SrPlugin/GhostEnv/internal/keyfilter"
+	"github.com/SrPlugin/GhostEnv/internal/server"
+	"github.com/SrPlugin/GhostEnv/internal/vault"
+)
+
+const serveTokensFile = "serve-tokens.json"
+
+type serveOptions struct {
+	Environments string
+	Host         string
+	Port         int
+	RateLimit    int
+}
+
+// projectPath resolves a config path relative to the project root.
+func projectPath(p string) string {
+	if p == "" || filepath.IsAbs(p) {
+		return p
+	}
+	if root := config.ProjectRoot(); root != "" {
+		return filepath.Join(root, p)
+	}
+	return p
+}
+
+func serveTokensPath(environment string) (string, error) {
+	_, vaultType, err := vault.GetVaultPath(environment)
+	if err != nil {
+		return "", err
+	}
+	if vaultType == vault.VaultTypeProject {
+		return filepath.Join(config.ProjectRoot(), config.ProjectVaultDir, serveTokensFile), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, config.ProjectVaultDir, serveTokensFile), nil
+}
+
+func (h *handlers) handleServe(opts serveOptions, password []byte, environment string) (err error) {
+	defer zeroBytes(password)
+	vaultPath, _, _ := vault.GetVaultPath(environment)
+	defer func() { auditLog(audit.ActionServe, vaultPath, environment, "", err) }()
+
+	cfg := config.Current()
+	if cfg == nil {
+		cfg = config.Default()
+	}
+	srvCfg := cfg.Microservices.Server
+	if opts.Host != "" {
+		srvCfg.Host = opts.Host
+	}
+	if opts.Port != 0 {
+		srvCfg.Port = opts.Port
+	}
+	if opts.RateLimit != 0 {
+		srvCfg.RateLimit = opts.RateLimit
+	}
+	if srvCfg.RateLimit == 0 {
+		srvCfg.RateLimit = server.DefaultRateLimit
+	}
+
+	envs := keyfilter.Split(opts.Environments)
+	if len(envs) == 0 {
+		env := environment
+		if env == "" {
+			env = cfg.Project.DefaultEnv
+		}
+		envs = []string{env}
+	}
+
+	vaults := make(map[string]server.Vault)
+	for _, env := range envs {
+		svc, err := h.getVaultService(env)
+		if err != nil {
+			return fmt.Errorf("failed to resolve vault for %s: %w", env, err)
+		}
+		secrets, err := loadRunSecrets(svc, password)
+		if err != nil {
+			return fmt.Errorf("%s: %w", env, err)
+		}
+		p, _, _ := vault.GetVaultPath(env)
+		vaults[env] = server.Vault{Path: p, Secrets: secrets}
+	}
+
+	tokensPath, err := serveTokensPath(environment)
+	if err != nil {
+		return fmt.Errorf("failed to resolve token file: %w", err)
+	}
+	tokens, err := server.LoadTokens(tokensPath)
+	if err != nil {
+		return err
+	}
+	if len(tokens.Tokens) == 0 {
+		fmt.Fprintln(os.Stderr, "Warning: no tokens defined; create one with 'ghostenv serve token create'")
+	}
+
+	var tlsConfig *tls.Config
+	if srvCfg.UseTLS {
+		var cert tls.Certificate
+		if srvCfg.CertFile != "" || srvCfg.KeyFile != "" {
+			cert, err = tls.LoadX509KeyPair(projectPath(srvCfg.CertFile), projectPath(srvCfg.KeyFile))
+			if err != nil {
+				return fmt.Errorf("failed to load TLS certificate: %w", err)
+			}
+		} else {
+			var certPEM []byte
+			cert, certPEM, err = server.SelfSignedCert(srvCfg.Host)
+			if err != nil {
+				return fmt.Errorf("failed to generate dev certificate: %w", err)
+			}
+			certPath := filepath.Join(filepath.Dir(tokensPath), "serve-cert.pem")
+			if writeErr := os.WriteFile(certPath, certPEM, 0644); writeErr == nil {
+				fmt.Fprintf(os.Stderr, "Using self-signed dev certificate %s (%s)\n", certPath, server.Fingerprint(cert))
+			}
+		}
+		tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	}
+
+	addr := net.JoinHostPort(srvCfg.Host, strconv.Itoa(srvCfg.Port))
+	scheme := "http"
+	if tlsConfig != nil {
+		scheme = "https"
+	}
+	fmt.Fprintf(os.Stderr, "Serving %s on %s://%s (rate limit %d/min per client)\n", strings.Join(envs, ", "), scheme, addr, srvCfg.RateLimit)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	srv := server.New(server.Options{
+		Addr:      addr,
+		TLSConfig: tlsConfig,
+		Tokens:    tokens,
+		Vaults:    vaults,
+		RateLimit: srvCfg.RateLimit,
+	})
+	return srv.ListenAndServe(ctx)
+}
+
+func (h *handlers) handleTokenCreate(name, patterns, environment string) (err error) {
+	vaultPath, _, _ := vault.GetVaultPath(environment)
+	rec := newAuditRecord(audit.ActionToken, vaultPath, environment, name)
+	defer func() { rec.done(err) }()
+	if environment == "" {
+		environment = config.Current().Project.DefaultEnv
+	}
+	path, err := serveTokensPath(environment)
+	if err != nil {
+		return err
+	}
+	store, err := server.LoadTokens(path)
+	if err != nil {
+		return err
+	}
+	value, err := store.Create(name, environment, keyfilter.Split(patterns))
+	if err != nil {
+		return err
+	}
+	if err = rec.commit(); err != nil {
+		return err
+	}
+	if err = store.Save(); err != nil {
+		return fmt.Errorf("failed to save token file: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Token '%s' created for %s (keys: %s). It is shown only once:\n", name, environment, orAll(patterns))
+	fmt.Println(value)
+	return nil
+}
+
+func (h *handlers) handleTokenList(environment string) error {
+	path, err := serveTokensPath(environment)
+	if err != nil {
+		return err
+	}
+	store, err := server.LoadTokens(path)
+	if err != nil {
+		return err
+	}
+	fmt.Println("--- Server Tokens ---")
+	for _, t := range store.Tokens {
+		fmt.Printf("%s\tenv=%s\tkeys=%s\tcreated=%s\n", t.Name, t.Environment, orAll(strings.Join(t.Patterns, ",")), t.CreatedAt.Format("2006-01-02"))
+	}
+	fmt.Printf("\nTotal: %d tokens\n", len(store.Tokens))
+	return nil
+}
+
+func (h *handlers) handleTokenRevoke(name, environment string) (err error) {
+	vaultPath, _, _ := vault.GetVaultPath(environment)
+	rec := newAuditRecord(audit.ActionToken, vaultPath, environment, name)
+	defer func() { rec.done(err) }()
+	path, err := serveTokensPath(environment)
+	if err != nil {
+		return err
+	}
+	store, err := server.LoadTokens(path)
+	if err != nil {
+		return err
+	}
+	if err = store.Revoke(name); err != nil {
+		return err
+	}
+	if err = rec.commit(); err != nil {
+		return err
+	}
+	if err = store.Save(); err != nil {
+		return fmt.Errorf("failed to save token file: %w", err)
+	}
+	fmt.Printf("Token '%s' revoked\n", name)
+	return nil
+}
+
+func orAll(patterns string) string {
+	if patterns == "" {
+		return "*"
+	}
+	return patterns
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SrPlugin/GhostEnv/internal/audit"
+	"github.com/SrPlugin/GhostEnv/internal/config"
+	"github.com/SrPlugin/GhostEnv/internal/injector"
+	"github.com/SrPlugin/GhostEnv/internal/keyfilter"
+	"github.com/SrPlugin/GhostEnv/internal/procfile"
+	"github.com/SrPlugin/GhostEnv/internal/vault"
+)
+
+type upProcess struct {
+	name    string
+	command string
+	env     []string
+}
+
+// resolveProcesses reads processes from the Procfile when one is given or
+// present, otherwise from the processes section of .ghostenv.yml. Env filters
+// in .ghostenv.yml apply to Procfile entries of the same name.
+func resolveProcesses(procfilePath string) ([]upProcess, error) {
+	cfg := config.Current()
+	hasCommands := false
+	if cfg != nil {
+		for _, pc := range cfg.Processes {
+			if pc.Command != "" {
+				hasCommands = true
+			}
+		}
+	}
+	if procfilePath == "" && !hasCommands {
+		candidate := "Procfile"
+		if root := config.ProjectRoot(); root != "" {
+			candidate = filepath.Join(root, "Procfile")
+		}
+		if _, err := os.Stat(candidate); err == nil {
+			procfilePath = candidate
+		}
+	}
+
+	var out []upProcess
+	if procfilePath != "" {
+		entries, err := procfile.ParseFile(procfilePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Procfile: %w", err)
+		}
+		for _, e := range entries {
+			p := upProcess{name: e.Name, command: e.Command}
+			if cfg != nil {
+				p.env = cfg.Processes[e.Name].Env
+			}
+			out = append(out, p)
+		}
+	} else if cfg != nil {
+		names := make([]string, 0, len(cfg.Processes))
+		for name := range cfg.Processes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			pc := cfg.Processes[name]
+			if pc.Command == "" {
+				return nil, fmt.Errorf("process %q has no command", name)
+			}
+			out = append(out, upProcess{name: name, command: pc.Command, env: pc.Env})
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no processes defined: add a Procfile or a processes section to %s", config.ProjectConfigName)
+	}
+	return out, nil
+}
+
+func (h *handlers) handleUp(procfilePath string, only []string, grace time.Duration, password []byte, environment string) (err error) {
+	defer zeroBytes(password)
+	vaultPath, _, _ := vault.GetVaultPath(environment)
+	rec := newAuditRecord(audit.ActionUp, vaultPath, environment, "")
+	defer func() { rec.done(err) }()
+	vaultService, err := h.getVaultService(environment)
+	if err != nil {
+		return fmt.Errorf("failed to resolve vault: %w", err)
+	}
+
+	procs, err := resolveProcesses(procfilePath)
+	if err != nil {
+		return err
+	}
+	if len(only) > 0 {
+		var selected []upProcess
+		for _, p := range procs {
+			if keyfilter.Match(only, p.name) {
+				selected = append(selected, p)
+			}
+		}
+		if len(selected) == 0 {
+			return fmt.Errorf("no processes match %s", strings.Join(only, ","))
+		}
+		procs = selected
+	}
+
+	secrets, err := loadRunSecrets(vaultService, password)
+	if err != nil {
+		return err
+	}
+
+	width := 0
+	var names []string
+	for _, p := range procs {
+		names = append(names, p.name)
+		if len(p.name) > width {
+			width = len(p.name)
+		}
+	}
+	rec.key = strings.Join(names, ",")
+	if err = rec.commit(); err != nil {
+		return err
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, stopSignals...)
+	defer signal.Stop(sig)
+
+	stdout := injector.NewPrefixGroup(os.Stdout)
+	stderr := injector.NewPrefixGroup(os.Stderr)
+	type exit struct {
+		name string
+		err  error
+	}
+	exits := make(chan exit, len(procs))
+	running := make(map[string]*injector.Process)
+	var writers []*injector.PrefixWriter
+
+	// Processes are stopped together, so the slowest one bounds shutdown at
+	// one grace period.
+	stopAll := func() {
+		var wg sync.WaitGroup
+		for _, p := range running {
+			wg.Go(func() { _ = p.Stop(grace) })
+		}
+		wg.Wait()
+		for _, w := range writers {
+			_ = w.Flush()
+		}
+	}
+
+	for _, p := range procs {
+		prefix := fmt.Sprintf("%-*s | ", width, p.name)
+		out := stdout.Writer(prefix)
+		errOut := stderr.Writer(prefix)
+		writers = append(writers, out, errOut)
+		command, args := injector.ShellCommand(p.command)
+		proc, startErr := h.runner.Start(command, args, keyfilter.Apply(secrets, p.env), injector.StartOptions{Stdout: out, Stderr: errOut, Group: true})
+		if startErr != nil {
+			stopAll()
+			return fmt.Errorf("failed to start %s: %w", p.name, startErr)
+		}
+		running[p.name] = proc
+		fmt.Fprintf(os.Stderr, "ghostenv: started %s (pid %d)\n", p.name, proc.Pid())
+		go func(name string, proc *injector.Process) {
+			exits <- exit{name: name, err: proc.Wait()}
+		}(p.name, proc)
+	}
+
+	select {
+	case e := <-exits:
+		if e.err != nil {
+			fmt.Fprintf(os.Stderr, "ghostenv: %s exited: %v; stopping all processes\n", e.name, e.err)
+			err = fmt.Errorf("process %s failed: %w", e.name, e.err)
+		} else {
+			fmt.Fprintf(os.Stderr, "ghostenv: %s exited; stopping all processes\n", e.name)
+		}
+	case <-sig:
+		fmt.Fprintln(os.Stderr, "ghostenv: stopping all processes")
+	}
+	stopAll()
+	return err
+}
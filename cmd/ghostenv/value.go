@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// readSetValue returns the value for set from argv, stdin ("-"), a file, or
+// a hidden prompt, so secrets do not have to appear in argv or shell history.
+func readSetValue(key string, args []string, fromFile string) ([]byte, error) {
+	if fromFile != "" {
+		if len(args) > 0 {
+			return nil, fmt.Errorf("--from-file cannot be combined with a value")
+		}
+		data, err := os.ReadFile(fromFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read value file: %w", err)
+		}
+		return data, nil
+	}
+	if len(args) > 0 {
+		if args[0] == "-" {
+			data, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read value from stdin: %w", err)
+			}
+			return trimTrailingNewline(data), nil
+		}
+		return []byte(args[0]), nil
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return nil, fmt.Errorf("no value given: pass VALUE, '-' to read stdin, --from-file, or --generate")
+	}
+	return promptSecretValue(key)
+}
+
+// trimTrailingNewline drops the newline added by echo or a here-string, but
+// leaves binary data untouched.
+func trimTrailingNewline(data []byte) []byte {
+	if !utf8.Valid(data) || bytes.IndexByte(data, 0) >= 0 {
+		return data
+	}
+	data = bytes.TrimSuffix(data, []byte("\n"))
+	return bytes.TrimSuffix(data, []byte("\r"))
+}
+
+func promptSecretValue(key string) ([]byte, error) {
+	fmt.Printf("Enter value for %s: ", key)
+	value, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Println()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read value: %w", err)
+	}
+	if len(value) == 0 {
+		return nil, fmt.Errorf("value cannot be empty")
+	}
+
+	fmt.Printf("Confirm value for %s: ", key)
+	confirm, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Println()
+	if err != nil {
+		zeroBytes(value)
+		return nil, fmt.Errorf("failed to read value: %w", err)
+	}
+	defer zeroBytes(confirm)
+	if !bytes.Equal(value, confirm) {
+		zeroBytes(value)
+		return nil, fmt.Errorf("values do not match")
+	}
+	return value, nil
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"fmt"
+
 	"github.com/SrPlugin/GhostEnv/internal/vault"
 )
 
@@ -11,3 +13,24 @@ func getVaultService(environment string) (vault.Service, error) {
 	}
 	return vault.NewService(vaultPath), nil
 }
+
+// handleWhere prints the vault path for environment and how it was resolved.
+func (h *handlers) handleWhere(environment string) error {
+	res, err := vault.Explain(environment)
+	if err != nil {
+		return err
+	}
+	state := "does not exist yet"
+	if vault.NewService(res.Path).Exists() {
+		state = "exists"
+	}
+	fmt.Printf("%s\n", res.Path)
+	fmt.Printf("  %s vault for %s, %s\n", res.Type, res.Environment, state)
+	for i, s := range res.Steps {
+		fmt.Printf("  %d. %s\n", i+1, s)
+	}
+	if shared := vault.SharedVaultPath(); shared != "" {
+		fmt.Printf("  shared vault (microservices.inheritance): %s\n", shared)
+	}
+	return nil
+}
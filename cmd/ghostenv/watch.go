@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+
+	"github.com/SrPlugin/GhostEnv/internal/audit"
+	"github.com/SrPlugin/GhostEnv/internal/cipher"
+	"github.com/SrPlugin/GhostEnv/internal/injector"
+	"github.com/SrPlugin/GhostEnv/internal/render"
+	"github.com/SrPlugin/GhostEnv/internal/storage"
+	"github.com/SrPlugin/GhostEnv/internal/vault"
+	"github.com/SrPlugin/GhostEnv/internal/watch"
+)
+
+// watchPaths are the vault files run --watch reloads from.
+func watchPaths(vaultPath string) []string {
+	paths := []string{vaultPath}
+	if shared := vault.SharedVaultPath(); shared != "" {
+		paths = append(paths, shared)
+	}
+	return paths
+}
+
+// runWatch keeps the child running and restarts it with freshly decrypted
+// secrets whenever the vault (or the shared vault) changes on disk.
+func (h *handlers) runWatch(command string, args []string, secrets map[string]string, load func() (map[string]string, error), vaultPath string, environment string, opts runOptions) error {
+	w := watch.New(watchPaths(vaultPath), watch.DefaultInterval, watch.DefaultDebounce)
+	defer w.Close()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, stopSignals...)
+	defer signal.Stop(sig)
+
+	proc, err := h.runner.Start(command, args, secrets, injector.StartOptions{Interactive: true})
+	if err != nil {
+		return fmt.Errorf("command execution failed: %w", err)
+	}
+
+	for {
+		select {
+		case <-proc.Done():
+			if err := proc.Wait(); err != nil {
+				return fmt.Errorf("command execution failed: %w", err)
+			}
+			return nil
+		case <-sig:
+			_ = proc.Stop(opts.GracePeriod)
+			return nil
+		case <-w.Events():
+			next, err := load()
+			if err == nil && opts.Strict {
+				err = checkStrict(next, environment)
+			}
+			var staged []stagedFile
+			if err == nil && len(opts.Templates) > 0 {
+				staged, err = stageTemplates(opts.Templates, next)
+			}
+			// The entry is written before the restart; under fail_closed a
+			// reload that cannot be logged is refused.
+			reloadCtx := audit.Context{Command: audit.SanitizeArgv(append([]string{command}, args...), next)}
+			if logErr := auditLogContext(reloadCtx, audit.ActionReload, vaultPath, environment, command, err); err == nil && logErr != nil {
+				err = fmt.Errorf("audit log unavailable (audit.fail_closed is set): %w", logErr)
+			}
+			if err != nil {
+				discardStaged(staged)
+				fmt.Fprintf(os.Stderr, "ghostenv: reload failed, keeping current process: %v\n", err)
+				continue
+			}
+			if maps.Equal(secrets, next) {
+				discardStaged(staged)
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "ghostenv: vault changed, restarting %s\n", command)
+			_ = proc.Stop(opts.GracePeriod)
+			if err := commitStaged(staged); err != nil {
+				return err
+			}
+			secrets = next
+			proc, err = h.runner.Start(command, args, secrets, injector.StartOptions{Interactive: true})
+			if err != nil {
+				return fmt.Errorf("command execution failed: %w", err)
+			}
+		}
+	}
+}
+
+// stagedFile is a template rendered next to its output, waiting to replace
+// it once a reload has succeeded.
+type stagedFile struct {
+	temp, output string
+}
+
+// stageTemplates renders each template to a temporary file in its output's
+// directory, leaving the files the running child uses untouched.
+func stageTemplates(specs []string, secrets map[string]string) ([]stagedFile, error) {
+	var staged []stagedFile
+	for _, s := range specs {
+		spec, err := render.ParseSpec(s)
+		if err != nil {
+			return staged, fmt.Errorf("invalid --template %q: %w", s, err)
+		}
+		dir := filepath.Dir(spec.Output)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return staged, fmt.Errorf("failed to create output directory: %w", err)
+		}
+		f, err := os.CreateTemp(dir, "."+filepath.Base(spec.Output)+".*")
+		if err != nil {
+			return staged, fmt.Errorf("failed to write rendered file: %w", err)
+		}
+		f.Close()
+		staged = append(staged, stagedFile{temp: f.Name(), output: spec.Output})
+		if err := render.RenderFile(spec.Input, f.Name(), secrets); err != nil {
+			return staged, err
+		}
+	}
+	return staged, nil
+}
+
+func commitStaged(staged []stagedFile) error {
+	for i, f := range staged {
+		if err := os.Rename(f.temp, f.output); err != nil {
+			discardStaged(staged[i:])
+			return fmt.Errorf("failed to replace %s: %w", f.output, err)
+		}
+	}
+	return nil
+}
+
+func discardStaged(staged []stagedFile) {
+	for _, f := range staged {
+		_ = os.Remove(f.temp)
+	}
+}
+
+// reloadKeys is the key cache while watching. It keeps the keys derived from
+// the password, so a reload decrypts without Argon2id unless a save gave the
+// vault a new salt; anything else is looked up in the cache it replaced.
+type reloadKeys struct {
+	mu       sync.Mutex
+	keys     map[string][]byte
+	password []byte
+	paths    []string
+	prev     cipher.KeyCache
+}
+
+// newReloadKeys installs the cache and derives the keys for paths.
+func newReloadKeys(password []byte, paths []string) *reloadKeys {
+	r := &reloadKeys{
+		keys:     make(map[string][]byte),
+		password: password,
+		paths:    paths,
+		prev:     cipher.CurrentKeyCache(),
+	}
+	cipher.SetKeyCache(r)
+	r.unlock()
+	return r
+}
+
+// unlock derives the key for every watched vault whose salt has no key yet.
+// Vaults that cannot be read are left for the load to report.
+func (r *reloadKeys) unlock() {
+	if len(r.password) == 0 {
+		return
+	}
+	for _, p := range r.paths {
+		data, err := storage.LoadVault(p)
+		if err != nil {
+			continue
+		}
+		salt, err := cipher.Salt(data)
+		if err != nil || cipher.CachedKey(salt) {
+			continue
+		}
+		key := cipher.DeriveKey(r.password, salt)
+		r.Put(salt, key)
+		zeroBytes(key)
+	}
+}
+
+func (r *reloadKeys) Get(salt []byte) ([]byte, bool) {
+	r.mu.Lock()
+	key, ok := r.keys[string(salt)]
+	r.mu.Unlock()
+	if ok {
+		return append([]byte(nil), key...), true
+	}
+	if r.prev != nil {
+		return r.prev.Get(salt)
+	}
+	return nil, false
+}
+
+func (r *reloadKeys) Put(salt, key []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys[string(salt)] = append([]byte(nil), key...)
+}
+
+// close restores the previous cache and wipes the derived keys.
+func (r *reloadKeys) close() {
+	cipher.SetKeyCache(r.prev)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for salt, key := range r.keys {
+		zeroBytes(key)
+		delete(r.keys, salt)
+	}
+}
@@ -0,0 +1,145 @@
+package agent
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	SocketEnv  = "GHOSTENV_AGENT_SOCK"
+	DefaultTTL = 15 * time.Minute
+)
+
+var (
+	ErrUnsupported  = errors.New("the ghostenv agent is not supported on this platform")
+	ErrNotRunning   = errors.New("agent is not running")
+	ErrPeerRejected = errors.New("peer credentials rejected")
+)
+
+const (
+	opGet    = "get"
+	opPut    = "put"
+	opLock   = "lock"
+	opStatus = "status"
+	opStop   = "stop"
+)
+
+type request struct {
+	Op   string `json:"op"`
+	Salt string `json:"salt,omitempty"`
+	Key  string `json:"key,omitempty"`
+	// Correlation is the client's audit correlation ID, recorded by the
+	// agent with the keys it hands out.
+	Correlation string `json:"correlation,omitempty"`
+}
+
+type response struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+	Key   string `json:"key,omitempty"`
+	Keys  int    `json:"keys,omitempty"`
+	TTL   string `json:"ttl,omitempty"`
+	Pid   int    `json:"pid,omitempty"`
+}
+
+type Status struct {
+	Keys int
+	TTL  time.Duration
+	Pid  int
+}
+
+// DefaultSocketPath returns $XDG_RUNTIME_DIR/ghostenv/agent.sock, falling
+// back to a per-user directory under the system temp dir.
+func DefaultSocketPath() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "ghostenv", "agent.sock")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("ghostenv-%d", os.Getuid()), "agent.sock")
+}
+
+func saltID(salt []byte) string {
+	return base64.StdEncoding.EncodeToString(salt)
+}
+
+type entry struct {
+	key      []byte
+	lastUsed time.Time
+}
+
+// keystore keeps derived keys in memory locked against swapping and wipes
+// them on eviction.
+type keystore struct {
+	mu   sync.Mutex
+	ttl  time.Duration
+	keys map[string]*entry
+}
+
+func newKeystore(ttl time.Duration) *keystore {
+	return &keystore{ttl: ttl, keys: make(map[string]*entry)}
+}
+
+func (s *keystore) get(id string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.keys[id]
+	if !ok {
+		return nil, false
+	}
+	e.lastUsed = time.Now()
+	out := make([]byte, len(e.key))
+	copy(out, e.key)
+	return out, true
+}
+
+func (s *keystore) put(id string, key []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if old, ok := s.keys[id]; ok {
+		wipe(old.key)
+	}
+	buf := make([]byte, len(key))
+	copy(buf, key)
+	lockMemory(buf)
+	s.keys[id] = &entry{key: buf, lastUsed: time.Now()}
+}
+
+func (s *keystore) evictIdle(now time.Time) {
+	if s.ttl <= 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, e := range s.keys {
+		if now.Sub(e.lastUsed) > s.ttl {
+			wipe(e.key)
+			delete(s.keys, id)
+		}
+	}
+}
+
+func (s *keystore) wipeAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, e := range s.keys {
+		wipe(e.key)
+		delete(s.keys, id)
+	}
+}
+
+func (s *keystore) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.keys)
+}
+
+func wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+	unlockMemory(b)
+}
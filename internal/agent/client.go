@@ -0,0 +1,85 @@
+package agent
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/SrPlugin/GhostEnv/internal/audit"
+)
+
+const dialTimeout = 2 * time.Second
+
+// Client talks to a running agent. It implements cipher.KeyCache; lookups
+// against an unreachable agent simply miss so commands fall back to the
+// password prompt.
+type Client struct {
+	socket string
+}
+
+func NewClient(socket string) *Client {
+	return &Client{socket: socket}
+}
+
+func (c *Client) call(req request) (*response, error) {
+	conn, err := net.DialTimeout("unix", c.socket, dialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotRunning, err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(dialTimeout))
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, err
+	}
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil {
+		return nil, fmt.Errorf("agent closed connection: %w", err)
+	}
+	var resp response
+	if err := json.Unmarshal(line, &resp); err != nil {
+		return nil, err
+	}
+	if !resp.OK {
+		return &resp, fmt.Errorf("agent: %s", resp.Error)
+	}
+	return &resp, nil
+}
+
+func (c *Client) Get(salt []byte) ([]byte, bool) {
+	resp, err := c.call(request{Op: opGet, Salt: saltID(salt), Correlation: audit.CorrelationID()})
+	if err != nil || resp.Key == "" {
+		return nil, false
+	}
+	key, err := base64.StdEncoding.DecodeString(resp.Key)
+	if err != nil {
+		return nil, false
+	}
+	return key, true
+}
+
+func (c *Client) Put(salt, key []byte) {
+	_, _ = c.call(request{Op: opPut, Salt: saltID(salt), Key: base64.StdEncoding.EncodeToString(key)})
+}
+
+func (c *Client) Lock() error {
+	_, err := c.call(request{Op: opLock})
+	return err
+}
+
+func (c *Client) Stop() error {
+	_, err := c.call(request{Op: opStop})
+	return err
+}
+
+func (c *Client) Status() (Status, error) {
+	resp, err := c.call(request{Op: opStatus})
+	if err != nil {
+		return Status{}, err
+	}
+	ttl, _ := time.ParseDuration(resp.TTL)
+	return Status{Keys: resp.Keys, TTL: ttl, Pid: resp.Pid}, nil
+}
@@ -0,0 +1,35 @@
+package agent
+
+import (
+	"fmt"
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+func checkPeer(conn *net.UnixConn, uid int) (string, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return "", err
+	}
+	var cred *unix.Xucred
+	var pid int
+	var credErr error
+	if err := raw.Control(func(fd uintptr) {
+		cred, credErr = unix.GetsockoptXucred(int(fd), unix.SOL_LOCAL, unix.LOCAL_PEERCRED)
+		if credErr == nil {
+			pid, _ = unix.GetsockoptInt(int(fd), unix.SOL_LOCAL, unix.LOCAL_PEERPID)
+		}
+	}); err != nil {
+		return "", err
+	}
+	if credErr != nil {
+		return "", credErr
+	}
+	if int(cred.Uid) != uid {
+		return "", fmt.Errorf("%w: uid %d", ErrPeerRejected, cred.Uid)
+	}
+	return fmt.Sprintf("uid %d pid %d", cred.Uid, pid), nil
+}
+
+func hardenProcess() {}
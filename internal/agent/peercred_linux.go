@@ -0,0 +1,37 @@
+package agent
+
+import (
+	"fmt"
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// checkPeer accepts only processes of uid and describes the peer for the
+// audit log.
+func checkPeer(conn *net.UnixConn, uid int) (string, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return "", err
+	}
+	var cred *unix.Ucred
+	var credErr error
+	if err := raw.Control(func(fd uintptr) {
+		cred, credErr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	}); err != nil {
+		return "", err
+	}
+	if credErr != nil {
+		return "", credErr
+	}
+	if int(cred.Uid) != uid {
+		return "", fmt.Errorf("%w: uid %d", ErrPeerRejected, cred.Uid)
+	}
+	return fmt.Sprintf("uid %d pid %d", cred.Uid, cred.Pid), nil
+}
+
+// hardenProcess keeps the agent out of core dumps and ptrace by other
+// processes of the same user.
+func hardenProcess() {
+	_ = unix.Prctl(unix.PR_SET_DUMPABLE, 0, 0, 0, 0)
+}
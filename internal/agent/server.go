@@ -0,0 +1,198 @@
+//go:build linux || darwin
+
+package agent
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/SrPlugin/GhostEnv/internal/audit"
+	"github.com/SrPlugin/GhostEnv/internal/config"
+	"golang.org/x/sys/unix"
+)
+
+type server struct {
+	store    *keystore
+	listener net.Listener
+	uid      int
+	stop     chan struct{}
+}
+
+// Serve runs the agent in the foreground until it is stopped or signalled.
+func Serve(socketPath string, ttl time.Duration) error {
+	if err := os.MkdirAll(filepath.Dir(socketPath), 0700); err != nil {
+		return fmt.Errorf("failed to create socket directory: %w", err)
+	}
+	if conn, err := net.DialTimeout("unix", socketPath, dialTimeout); err == nil {
+		conn.Close()
+		return fmt.Errorf("an agent is already listening on %s", socketPath)
+	}
+	_ = os.Remove(socketPath)
+
+	hardenProcess()
+
+	oldMask := unix.Umask(0177)
+	l, err := net.Listen("unix", socketPath)
+	unix.Umask(oldMask)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", socketPath, err)
+	}
+	if err := os.Chmod(socketPath, 0600); err != nil {
+		l.Close()
+		return fmt.Errorf("failed to restrict socket permissions: %w", err)
+	}
+
+	s := &server{
+		store:    newKeystore(ttl),
+		listener: l,
+		uid:      os.Getuid(),
+		stop:     make(chan struct{}),
+	}
+	defer os.Remove(socketPath)
+	defer s.store.wipeAll()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(sig)
+
+	go s.janitor()
+	go func() {
+		select {
+		case <-sig:
+		case <-s.stop:
+		}
+		l.Close()
+	}()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			continue
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *server) janitor() {
+	interval := s.store.ttl / 4
+	if interval <= 0 || interval > time.Minute {
+		interval = time.Minute
+	}
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case now := <-ticker.C:
+			s.store.evictIdle(now)
+		}
+	}
+}
+
+func (s *server) handle(conn net.Conn) {
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(dialTimeout))
+
+	enc := json.NewEncoder(conn)
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return
+	}
+	peer, err := checkPeer(uc, s.uid)
+	if err != nil {
+		_ = enc.Encode(response{Error: err.Error()})
+		return
+	}
+
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err != nil {
+		return
+	}
+	var req request
+	if err := json.Unmarshal(line, &req); err != nil {
+		_ = enc.Encode(response{Error: "malformed request"})
+		return
+	}
+	_ = enc.Encode(s.dispatch(req, peer))
+}
+
+// dispatch serves one request from peer ("uid 1000 pid 4242"). Handing out a
+// cached key is audited with the client's correlation ID; if that fails
+// under audit.fail_closed the lookup misses and the client asks for the
+// password instead.
+func (s *server) dispatch(req request, peer string) response {
+	switch req.Op {
+	case opGet:
+		key, ok := s.store.get(req.Salt)
+		if !ok {
+			return response{OK: true}
+		}
+		defer wipe(key)
+		ctx := audit.Context{Client: peer, CorrelationID: audit.SanitizeCorrelationID(req.Correlation)}
+		if err := ctx.Log(audit.ActionAgentGet, "", "", "", true, ""); err != nil {
+			return response{OK: true}
+		}
+		return response{OK: true, Key: base64.StdEncoding.EncodeToString(key)}
+	case opPut:
+		key, err := base64.StdEncoding.DecodeString(req.Key)
+		if err != nil || len(key) != config.KeySize || req.Salt == "" {
+			return response{Error: "invalid key"}
+		}
+		s.store.put(req.Salt, key)
+		wipe(key)
+		return response{OK: true}
+	case opLock:
+		s.store.wipeAll()
+		return response{OK: true}
+	case opStatus:
+		return response{OK: true, Keys: s.store.len(), TTL: s.store.ttl.String(), Pid: os.Getpid()}
+	case opStop:
+		s.store.wipeAll()
+		select {
+		case <-s.stop:
+		default:
+			close(s.stop)
+		}
+		return response{OK: true}
+	}
+	return response{Error: fmt.Sprintf("unknown op %q", req.Op)}
+}
+
+// Spawn starts exe with args as a detached agent process in its own session.
+func Spawn(exe string, args []string) error {
+	cmd := exec.Command(exe, args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
+
+func lockMemory(b []byte) {
+	if len(b) > 0 {
+		_ = unix.Mlock(b)
+	}
+}
+
+func unlockMemory(b []byte) {
+	if len(b) > 0 {
+		_ = unix.Munlock(b)
+	}
+}
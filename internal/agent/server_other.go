@@ -0,0 +1,17 @@
+//go:build !linux && !darwin
+
+package agent
+
+import "time"
+
+func Serve(socketPath string, ttl time.Duration) error {
+	return ErrUnsupported
+}
+
+func Spawn(exe string, args []string) error {
+	return ErrUnsupported
+}
+
+func lockMemory(b []byte) {}
+
+func unlockMemory(b []byte) {}
@@ -0,0 +1,54 @@
+package audit
+
+import (
+	"regexp"
+	"strings"
+)
+
+const redacted = "[redacted]"
+
+// sensitiveFlag matches option names that usually carry a credential.
+var sensitiveFlag = regexp.MustCompile(`(?i)^--?[a-z0-9_-]*(pass|pwd|secret|token|key|auth|credential)[a-z0-9_-]*$`)
+
+// SanitizeArgv returns argv fit for the audit log: arguments containing one
+// of the secret values, the values of credential-like options (--password x,
+// --token=x) and credentials in URLs are replaced by "[redacted]".
+func SanitizeArgv(argv []string, secrets map[string]string) []string {
+	out := make([]string, len(argv))
+	redactNext := false
+	for i, arg := range argv {
+		switch {
+		case redactNext:
+			out[i], redactNext = redacted, false
+			continue
+		case containsSecret(arg, secrets):
+			out[i] = redacted
+			continue
+		}
+		if name, _, ok := strings.Cut(arg, "="); ok && sensitiveFlag.MatchString(name) {
+			out[i] = name + "=" + redacted
+			continue
+		}
+		if sensitiveFlag.MatchString(arg) {
+			redactNext = true
+		}
+		out[i] = stripURLPassword(arg)
+	}
+	return out
+}
+
+// containsSecret ignores very short values, which would match by accident.
+func containsSecret(arg string, secrets map[string]string) bool {
+	for _, v := range secrets {
+		if len(v) >= 4 && strings.Contains(arg, v) {
+			return true
+		}
+	}
+	return false
+}
+
+var urlUserinfo = regexp.MustCompile(`(://[^/:@\s]*):[^/@\s]*@`)
+
+func stripURLPassword(arg string) string {
+	return urlUserinfo.ReplaceAllString(arg, "${1}:"+redacted+"@")
+}
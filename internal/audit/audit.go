@@ -1,43 +1,86 @@
 package audit
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"os"
+	"os/user"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/SrPlugin/GhostEnv/internal/config"
+	"github.com/SrPlugin/GhostEnv/internal/version"
 )
 
 const (
-	ActionSet             = "set"
-	ActionGet             = "get"
-	ActionList             = "list"
-	ActionRemove           = "remove"
-	ActionImport           = "import"
-	ActionExport           = "export"
-	ActionRun              = "run"
-	ActionChangePassword   = "change-password"
-	ActionStats            = "stats"
-	ActionCreateShares     = "create-shares"
-	ActionRecover          = "recover"
+	ActionSet            = "set"
+	ActionGet            = "get"
+	ActionList           = "list"
+	ActionRemove         = "remove"
+	ActionImport         = "import"
+	ActionExport         = "export"
+	ActionRun            = "run"
+	ActionChangePassword = "change-password"
+	ActionStats          = "stats"
+	ActionCreateShares   = "create-shares"
+	ActionRecover        = "recover"
+	ActionRender         = "render"
+	ActionReload         = "reload"
+	ActionUp             = "up"
+	ActionAgentStart     = "agent-start"
+	ActionAgentLock      = "agent-lock"
+	ActionAgentStop      = "agent-stop"
+	ActionAgentGet       = "agent-get"
+	ActionAuditUnmask    = "audit-unmask"
+	ActionServe          = "serve"
+	ActionToken          = "token"
+	ActionDB             = "db"
+	ActionRotate         = "rotate"
+	ActionDiff           = "diff"
+	ActionCopy           = "copy"
+	ActionPromote        = "promote"
+	ActionCheck          = "check"
+)
+
+const (
+	LevelDebug = "debug"
+	LevelInfo  = "info"
+	LevelWarn  = "warn"
 )
 
 type Entry struct {
-	Timestamp   string `json:"timestamp"`
-	Action      string `json:"action"`
-	Environment string `json:"environment,omitempty"`
-	VaultPath   string `json:"vault_path,omitempty"`
-	Key         string `json:"key,omitempty"`
-	Success     bool   `json:"success"`
-	Error       string `json:"error,omitempty"`
+	Seq           uint64   `json:"seq,omitempty"`
+	Timestamp     string   `json:"timestamp"`
+	Level         string   `json:"level"`
+	User          string   `json:"user,omitempty"`
+	Host          string   `json:"host,omitempty"`
+	PID           int      `json:"pid,omitempty"`
+	PPID          int      `json:"ppid,omitempty"`
+	Version       string   `json:"version,omitempty"`
+	Action        string   `json:"action"`
+	Environment   string   `json:"environment,omitempty"`
+	VaultPath     string   `json:"vault_path,omitempty"`
+	Key           string   `json:"key,omitempty"`
+	Command       []string `json:"command,omitempty"`
+	Client        string   `json:"client,omitempty"`
+	CorrelationID string   `json:"correlation_id,omitempty"`
+	Success       bool     `json:"success"`
+	Error         string   `json:"error,omitempty"`
+	Transaction   string   `json:"transaction,omitempty"`
+	// Prev is the SHA-256 of the previous line and MAC the HMAC of this
+	// line without the mac field; MAC must stay the last field.
+	Prev string `json:"prev,omitempty"`
+	MAC  string `json:"mac,omitempty"`
 }
 
 var (
 	mu       sync.Mutex
 	disabled bool
+	failErr  error
 )
 
 func init() {
@@ -46,51 +89,262 @@ func init() {
 	}
 }
 
-func Log(action, vaultPath, environment, key string, success bool, errMsg string) {
-	if disabled {
-		return
+// Log records one operation. Write failures are reported on stderr; with
+// audit.fail_closed they are also returned (and kept for Err) so the caller
+// can refuse to go on.
+func Log(action, vaultPath, environment, key string, success bool, errMsg string) error {
+	return write(newEntry(action, vaultPath, environment, key, success, errMsg))
+}
+
+// Transaction groups the entries of an operation that spans several keys or
+// vaults (copy, promote) under one ID, so they can be read back together.
+type Transaction struct {
+	ID string
+}
+
+func Begin() *Transaction {
+	return &Transaction{ID: randomID()}
+}
+
+func (t *Transaction) Log(action, vaultPath, environment, key string, success bool, errMsg string) error {
+	e := newEntry(action, vaultPath, environment, key, success, errMsg)
+	e.Transaction = t.ID
+	return write(e)
+}
+
+// Context adds what the process knows about the caller: the child command
+// of run, and for serve and the agent the client and the ID correlating its
+// request. An empty CorrelationID keeps the process's own. Reveals marks an
+// operation that showed secret values, which is never logged below info.
+type Context struct {
+	Command       []string
+	Client        string
+	CorrelationID string
+	Reveals       bool
+}
+
+func (c Context) Log(action, vaultPath, environment, key string, success bool, errMsg string) error {
+	e := newEntry(action, vaultPath, environment, key, success, errMsg)
+	e.Command, e.Client = c.Command, c.Client
+	if c.Reveals && e.Level == LevelDebug {
+		e.Level = LevelInfo
 	}
-	cfg := config.Current()
-	if cfg != nil && !cfg.Audit.Enabled {
-		return
+	if c.CorrelationID != "" {
+		e.CorrelationID = c.CorrelationID
 	}
+	return write(e)
+}
+
+func randomID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Err returns the first write failure of this process under fail_closed.
+func Err() error {
 	mu.Lock()
 	defer mu.Unlock()
+	return failErr
+}
+
+// FailClosed reports whether entries that cannot be written must stop the
+// operation (audit.fail_closed on an enabled log).
+func FailClosed() bool {
+	cfg := currentConfig()
+	return !disabled && cfg.Audit.IsEnabled() && cfg.Audit.FailClosed
+}
+
+// Preflight checks that the configured sink can be written before an
+// operation starts. It only does so under fail_closed.
+func Preflight(vaultPath string) error {
+	if !FailClosed() {
+		return nil
+	}
+	s, err := newSink(currentConfig().Audit, vaultPath)
+	if err == nil {
+		err = s.probe()
+	}
+	if err != nil {
+		return fmt.Errorf("audit log unavailable (audit.fail_closed is set): %w", err)
+	}
+	return nil
+}
+
+// levelOf ranks entries for log_level: failures are warnings, commands that
+// only read metadata (key names, counts, hashes) are debug and everything
+// that reveals, injects or changes secrets is info.
+func levelOf(action string, success bool) string {
+	if !success {
+		return LevelWarn
+	}
+	switch action {
+	case ActionList, ActionStats, ActionDiff, ActionCheck:
+		return LevelDebug
+	}
+	return LevelInfo
+}
+
+func enabledAt(level, threshold string) bool {
+	rank := map[string]int{LevelDebug: 0, LevelInfo: 1, LevelWarn: 2}
+	return rank[level] >= rank[threshold]
+}
+
+// CorrelationEnv lets a caller (a CI job, a wrapper script) tie the entries
+// of several ghostenv processes together.
+const CorrelationEnv = "GHOSTENV_CORRELATION_ID"
+
+type processInfo struct {
+	user, host    string
+	pid, ppid     int
+	correlationID string
+}
+
+var (
+	procOnce sync.Once
+	proc     processInfo
+)
+
+func process() processInfo {
+	procOnce.Do(func() {
+		proc = processInfo{user: currentUser(), pid: os.Getpid(), ppid: os.Getppid()}
+		proc.host, _ = os.Hostname()
+		proc.correlationID = SanitizeCorrelationID(os.Getenv(CorrelationEnv))
+		if proc.correlationID == "" {
+			proc.correlationID = randomID()
+		}
+	})
+	return proc
+}
 
-	entry := Entry{
-		Timestamp:   time.Now().UTC().Format(time.RFC3339),
-		Action:      action,
-		Environment: environment,
-		VaultPath:   vaultPath,
-		Key:         key,
-		Success:     success,
-		Error:       errMsg,
+// CorrelationID identifies this process's entries; the agent records it for
+// the keys it hands out.
+func CorrelationID() string {
+	return process().correlationID
+}
+
+// NewCorrelationID is for servers that assign one per request.
+func NewCorrelationID() string {
+	return randomID()
+}
+
+// SanitizeCorrelationID accepts IDs supplied by clients only if they are
+// short and made of safe characters, so they cannot forge log structure.
+func SanitizeCorrelationID(id string) string {
+	if id == "" || len(id) > 128 {
+		return ""
 	}
-	if cfg != nil && cfg.Audit.MaskKeys {
-		entry.Key = "[redacted]"
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("._:-", c)) {
+			return ""
+		}
+	}
+	return id
+}
+
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return os.Getenv("USERNAME")
+}
+
+func newEntry(action, vaultPath, environment, key string, success bool, errMsg string) Entry {
+	p := process()
+	return Entry{
+		Timestamp:     time.Now().UTC().Format(time.RFC3339),
+		Level:         levelOf(action, success),
+		User:          p.user,
+		Host:          p.host,
+		PID:           p.pid,
+		PPID:          p.ppid,
+		Version:       version.Version,
+		Action:        action,
+		Environment:   environment,
+		VaultPath:     vaultPath,
+		Key:           key,
+		CorrelationID: p.correlationID,
+		Success:       success,
+		Error:         errMsg,
+	}
+}
+
+func currentConfig() *config.Config {
+	if cfg := config.Current(); cfg != nil {
+		return cfg
 	}
+	return config.Default()
+}
 
-	logPath := resolveLogPath(vaultPath)
-	if logPath == "" {
-		return
+func write(entry Entry) error {
+	if disabled {
+		return nil
+	}
+	cfg := currentConfig()
+	if !cfg.Audit.IsEnabled() || !enabledAt(entry.Level, cfg.Audit.LogLevel) {
+		return nil
 	}
 
-	dir := filepath.Dir(logPath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return
+	mu.Lock()
+	defer mu.Unlock()
+	var err error
+	if cfg.Audit.MaskKeys {
+		err = maskEntry(&entry)
+	}
+	if err == nil {
+		err = emit(cfg.Audit, entry.VaultPath, &entry)
+	}
+	if err == nil {
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "ghostenv: audit: %v\n", err)
+	if !cfg.Audit.FailClosed {
+		return nil
+	}
+	if failErr == nil {
+		failErr = err
 	}
+	return err
+}
 
-	f, err := os.OpenFile(logPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+func emit(cfg config.AuditConfig, vaultPath string, entry *Entry) error {
+	s, err := newSink(cfg, vaultPath)
+	if err != nil {
+		return err
+	}
+	key, err := Key(true)
 	if err != nil {
-		return
+		return err
 	}
-	defer f.Close()
+	headPath := s.headPath()
+	if err := os.MkdirAll(filepath.Dir(headPath), 0700); err != nil {
+		return err
+	}
+	unlock, err := lock(headPath + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
-	data, err := json.Marshal(entry)
+	seq, prev := s.tip(key)
+	entry.Seq, entry.Prev = seq+1, prev
+	body, err := json.Marshal(entry)
 	if err != nil {
-		return
+		return err
+	}
+	line := seal(key, body)
+	if err := s.write(entry, line); err != nil {
+		return err
 	}
-	_, _ = fmt.Fprintln(f, string(data))
+	return writeHead(headPath, key, head{Seq: entry.Seq, Hash: lineHash(line)})
+}
+
+// LogPath is the audit file for vaultPath when audit.output is file.
+func LogPath(vaultPath string) string {
+	return resolveLogPath(vaultPath)
 }
 
 func resolveLogPath(vaultPath string) string {
@@ -0,0 +1,258 @@
+package audit
+
+import (
+	"bytes"
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/SrPlugin/GhostEnv/internal/config"
+)
+
+// Entries are chained: each carries a sequence number and the SHA-256 of the
+// previous line, and ends with an HMAC over the rest of the line. The HMAC key
+// is derived from an audit secret kept apart from every vault password, so
+// an entry cannot be edited, dropped or reordered without the key.
+const (
+	SecretEnv      = "GHOSTENV_AUDIT_SECRET"
+	KeyFileName    = "audit.key"
+	auditKeyInfo   = "ghostenv audit log v1"
+	macFieldPrefix = `,"mac":"`
+)
+
+var ErrNoKey = errors.New("no audit key")
+
+// keyPath returns the configured audit secret location: the environment
+// variable, audit.key_file, or ~/.ghostenv/audit.key.
+func keyPath(cfg config.AuditConfig) string {
+	if cfg.KeyFile != "" {
+		p := cfg.KeyFile
+		if !filepath.IsAbs(p) {
+			if root := config.ProjectRoot(); root != "" {
+				p = filepath.Join(root, p)
+			}
+		}
+		return filepath.Clean(p)
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".ghostenv", KeyFileName)
+}
+
+// Key derives the HMAC key from the audit secret. With create set, a missing
+// key file is generated (32 random bytes, mode 0600); otherwise it is
+// ErrNoKey.
+func Key(create bool) ([]byte, error) {
+	cfg := currentConfig().Audit
+	secret := []byte(os.Getenv(SecretEnv))
+	if len(secret) == 0 {
+		path := keyPath(cfg)
+		data, err := os.ReadFile(path)
+		switch {
+		case os.IsNotExist(err) && create:
+			if data, err = createSecretFile(path, "audit key"); err != nil {
+				return nil, err
+			}
+		case os.IsNotExist(err):
+			return nil, fmt.Errorf("%w: set %s or create %s", ErrNoKey, SecretEnv, path)
+		case err != nil:
+			return nil, fmt.Errorf("failed to read audit key: %w", err)
+		}
+		secret = bytes.TrimSpace(data)
+	}
+	return hkdf.Key(sha256.New, secret, nil, auditKeyInfo, sha256.Size)
+}
+
+// createSecretFile writes 32 random bytes in hex to a new file; if another
+// process got there first its content is used.
+func createSecretFile(path, what string) ([]byte, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	data := []byte(hex.EncodeToString(b) + "\n")
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if os.IsExist(err) {
+		return os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", what, err)
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func mac(key, body []byte) string {
+	m := hmac.New(sha256.New, key)
+	m.Write(body)
+	return hex.EncodeToString(m.Sum(nil))
+}
+
+func lineHash(line []byte) string {
+	sum := sha256.Sum256(line)
+	return hex.EncodeToString(sum[:])
+}
+
+// seal appends the mac field to a marshalled entry that has none.
+func seal(key, body []byte) []byte {
+	sig := mac(key, body)
+	out := make([]byte, 0, len(body)+len(macFieldPrefix)+len(sig)+2)
+	out = append(out, body[:len(body)-1]...)
+	out = append(out, macFieldPrefix...)
+	out = append(out, sig...)
+	return append(out, `"}`...)
+}
+
+// unseal splits a line into the body that was signed and its mac.
+func unseal(line []byte) (body []byte, sig string, ok bool) {
+	i := bytes.LastIndex(line, []byte(macFieldPrefix))
+	if i < 0 || !bytes.HasSuffix(line, []byte(`"}`)) {
+		return nil, "", false
+	}
+	sig = string(line[i+len(macFieldPrefix) : len(line)-2])
+	body = append(append([]byte(nil), line[:i]...), '}')
+	return body, sig, true
+}
+
+func validMAC(key, line []byte) bool {
+	body, sig, ok := unseal(line)
+	if !ok {
+		return false
+	}
+	want := mac(key, body)
+	return hmac.Equal([]byte(sig), []byte(want))
+}
+
+// head is the last link of a chain, kept next to the log (or in the key's
+// directory for sinks that cannot be read back) so truncation can be told
+// from a log that simply ends.
+type head struct {
+	Seq  uint64 `json:"seq"`
+	Hash string `json:"hash"`
+	MAC  string `json:"mac"`
+}
+
+func (h head) signed(key []byte) string {
+	return mac(key, []byte(fmt.Sprintf("%d:%s", h.Seq, h.Hash)))
+}
+
+func readHead(path string, key []byte) (head, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return head{}, err
+	}
+	var h head
+	if err := json.Unmarshal(data, &h); err != nil {
+		return head{}, fmt.Errorf("invalid chain head %s: %w", path, err)
+	}
+	if !hmac.Equal([]byte(h.MAC), []byte(h.signed(key))) {
+		return head{}, fmt.Errorf("chain head %s has an invalid signature", path)
+	}
+	return h, nil
+}
+
+func writeHead(path string, key []byte, h head) error {
+	h.MAC = h.signed(key)
+	data, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// lastLine returns the last non-empty line of path.
+func lastLine(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	const chunk = 4096
+	var buf []byte
+	for off := fi.Size(); off > 0; {
+		n := int64(chunk)
+		if off < n {
+			n = off
+		}
+		off -= n
+		b := make([]byte, n)
+		if _, err := f.ReadAt(b, off); err != nil && err != io.EOF {
+			return nil, err
+		}
+		buf = append(b, buf...)
+		trimmed := bytes.TrimRight(buf, "\r\n")
+		if i := bytes.LastIndexByte(trimmed, '\n'); i >= 0 {
+			return trimmed[i+1:], nil
+		}
+		if off == 0 {
+			return trimmed, nil
+		}
+	}
+	return nil, nil
+}
+
+// link returns the sequence number and hash to continue the chain after line.
+// Entries written before chaining have no sequence number; the chain then
+// starts at 1 and still links to them by hash.
+func link(line []byte) (uint64, string) {
+	if len(line) == 0 {
+		return 0, ""
+	}
+	var e struct {
+		Seq uint64 `json:"seq"`
+	}
+	_ = json.Unmarshal(line, &e)
+	return e.Seq, lineHash(line)
+}
+
+// lock serialises writers of one chain across processes with an exclusive
+// lock file. A lock older than staleLock is assumed to be left by a crash.
+func lock(path string) (func(), error) {
+	const staleLock = 10 * time.Second
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if fi, err := os.Stat(path); err == nil && time.Since(fi.ModTime()) > staleLock {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for %s", path)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// stateDir holds chain heads for sinks that are not files.
+func stateDir(cfg config.AuditConfig) string {
+	return filepath.Dir(keyPath(cfg))
+}
@@ -0,0 +1,182 @@
+package audit
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/SrPlugin/GhostEnv/internal/config"
+)
+
+// With audit.mask_keys, key names are logged as an HMAC-SHA256 under a
+// project-specific salt, so entries for the same key can be correlated within
+// a project without the name showing up in the log or wherever it is
+// shipped, and not across projects. The salt is derived from a secret kept
+// next to the audit key, outside the project. The names are recovered by
+// hashing the keys of the vault (see Unmasker).
+const (
+	SaltFileName = "audit.salt"
+	maskPrefix   = "hmac:"
+)
+
+// SaltPath is the file holding the secret the project salts are derived
+// from: audit.salt in the directory of the audit key.
+func SaltPath() string {
+	return filepath.Join(filepath.Dir(keyPath(currentConfig().Audit)), SaltFileName)
+}
+
+// Salt returns the salt of the current project, generating the secret in
+// SaltPath (mode 0600) when create is set.
+func Salt(create bool) ([]byte, error) {
+	path := SaltPath()
+	data, err := os.ReadFile(path)
+	switch {
+	case os.IsNotExist(err) && create:
+		if data, err = createSecretFile(path, "audit salt"); err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, fmt.Errorf("failed to read audit salt: %w", err)
+	}
+	m := hmac.New(sha256.New, bytes.TrimSpace(data))
+	m.Write([]byte(saltProject()))
+	return m.Sum(nil), nil
+}
+
+// saltProject identifies the project a salt belongs to: its project.name,
+// or its root directory when it has no name.
+func saltProject() string {
+	if name := currentConfig().Project.Name; name != "" {
+		return "name:" + name
+	}
+	root := config.ProjectRoot()
+	if abs, err := filepath.Abs(root); err == nil {
+		root = abs
+	}
+	return "root:" + root
+}
+
+// MaskKey returns the masked form of key under salt, e.g.
+// "hmac:3f1c9a0b7d2e4f65".
+func MaskKey(salt []byte, key string) string {
+	m := hmac.New(sha256.New, salt)
+	m.Write([]byte(key))
+	return maskPrefix + hex.EncodeToString(m.Sum(nil))[:16]
+}
+
+// IsMasked reports whether a logged key is a MaskKey hash.
+func IsMasked(key string) bool {
+	return strings.HasPrefix(key, maskPrefix)
+}
+
+var (
+	namesMu sync.Mutex
+	names   = make(map[string]bool)
+)
+
+// NoteKeys registers key names this process works with. Under mask_keys they
+// are masked wherever they appear in an entry's error or command, not only
+// in its key field.
+func NoteKeys(keys ...string) {
+	namesMu.Lock()
+	defer namesMu.Unlock()
+	for _, k := range keys {
+		if k != "" {
+			names[k] = true
+		}
+	}
+}
+
+func maskEntry(entry *Entry) error {
+	namesMu.Lock()
+	scrub := make([]string, 0, len(names)+1)
+	for k := range names {
+		scrub = append(scrub, k)
+	}
+	namesMu.Unlock()
+	// Only actions that log a secret name in Key have it masked; the others
+	// keep their file, pattern or command readable.
+	maskKey := entry.Key != "" && contains(keyActions, entry.Action)
+	if maskKey {
+		scrub = append(scrub, entry.Key)
+	}
+	if len(scrub) == 0 {
+		return nil
+	}
+	salt, err := Salt(true)
+	if err != nil {
+		return err
+	}
+	// Longest first, so a name is not half-replaced through a shorter one
+	// it contains.
+	sort.Slice(scrub, func(i, j int) bool { return len(scrub[i]) > len(scrub[j]) })
+	for _, k := range scrub {
+		masked := MaskKey(salt, k)
+		entry.Error = replaceName(entry.Error, k, masked)
+		command := make([]string, len(entry.Command))
+		for i, arg := range entry.Command {
+			command[i] = replaceName(arg, k, masked)
+		}
+		entry.Command = command
+	}
+	if maskKey {
+		entry.Key = MaskKey(salt, entry.Key)
+	}
+	return nil
+}
+
+// replaceName replaces name in s where it stands on its own, so that a key
+// "DB" does not mask part of "DB_HOST".
+func replaceName(s, name, with string) string {
+	var b strings.Builder
+	for {
+		i := strings.Index(s, name)
+		if i < 0 {
+			b.WriteString(s)
+			return b.String()
+		}
+		end := i + len(name)
+		if (i > 0 && isNameByte(s[i-1])) || (end < len(s) && isNameByte(s[end])) {
+			b.WriteString(s[:end])
+		} else {
+			b.WriteString(s[:i] + with)
+		}
+		s = s[end:]
+	}
+}
+
+func isNameByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// Unmasker maps hashes back to the key names it was given.
+type Unmasker struct {
+	names []string
+	table map[string]string
+}
+
+func NewUnmasker(names []string) *Unmasker {
+	return &Unmasker{names: names}
+}
+
+// Key returns the name behind hash, or false if none of the names hash to it
+// or the salt is missing.
+func (u *Unmasker) Key(hash string) (string, bool) {
+	if u.table == nil {
+		u.table = make(map[string]string)
+		if salt, err := Salt(false); err == nil {
+			for _, name := range u.names {
+				u.table[MaskKey(salt, name)] = name
+			}
+		}
+	}
+	name, ok := u.table[hash]
+	return name, ok
+}
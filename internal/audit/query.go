@@ -0,0 +1,246 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/SrPlugin/GhostEnv/internal/keyfilter"
+)
+
+// ReadEntries reads path and its rotated files, oldest first. Lines that are
+// not entries are skipped.
+func ReadEntries(path string) ([]Entry, error) {
+	files := LogFiles(path)
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no audit log at %s", path)
+	}
+	var entries []Entry
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		sc := bufio.NewScanner(f)
+		sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for sc.Scan() {
+			var e Entry
+			if json.Unmarshal(sc.Bytes(), &e) == nil && e.Action != "" {
+				entries = append(entries, e)
+			}
+		}
+		err = sc.Err()
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+	}
+	return entries, nil
+}
+
+// Time parses the entry timestamp; the zero time if it is malformed.
+func (e Entry) Time() time.Time {
+	t, _ := time.Parse(time.RFC3339, e.Timestamp)
+	return t
+}
+
+// Filter selects entries. Zero fields match everything; Keys are globs.
+type Filter struct {
+	Actions     []string
+	Keys        []string
+	Environment string
+	User        string
+	Since       time.Time
+	Until       time.Time
+	Success     *bool
+}
+
+func (f Filter) Match(e Entry) bool {
+	if len(f.Actions) > 0 && !contains(f.Actions, e.Action) {
+		return false
+	}
+	if len(f.Keys) > 0 && (e.Key == "" || !keyfilter.Match(f.Keys, e.Key)) {
+		return false
+	}
+	if f.Environment != "" && e.Environment != f.Environment {
+		return false
+	}
+	if f.User != "" && e.User != f.User {
+		return false
+	}
+	if t := e.Time(); (!f.Since.IsZero() && t.Before(f.Since)) || (!f.Until.IsZero() && t.After(f.Until)) {
+		return false
+	}
+	if f.Success != nil && e.Success != *f.Success {
+		return false
+	}
+	return true
+}
+
+func (f Filter) Apply(entries []Entry) []Entry {
+	var out []Entry
+	for _, e := range entries {
+		if f.Match(e) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// keyActions name a secret in Entry.Key; other actions use the field for a
+// command, file or pattern.
+var keyActions = []string{ActionGet, ActionSet, ActionRemove, ActionRotate, ActionCopy, ActionPromote, ActionServe}
+
+// IsAuthFailure reports a failed unlock: a wrong vault password or
+// passphrase, or a request the server refused as unauthorized.
+func IsAuthFailure(e Entry) bool {
+	if e.Success {
+		return false
+	}
+	msg := strings.ToLower(e.Error)
+	for _, s := range []string{"decryption failed", "wrong password", "wrong passphrase", "unauthorized", "authentication failed"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+type Count struct {
+	Name   string
+	Total  int
+	Failed int
+}
+
+type Spike struct {
+	Start    time.Time
+	End      time.Time
+	Failures int
+}
+
+type Access struct {
+	Key   string
+	Entry Entry
+}
+
+type Summary struct {
+	Entries      int
+	First, Last  time.Time
+	ByAction     []Count
+	ByUser       []Count
+	AuthFailures int
+	Spikes       []Spike
+	LastAccess   []Access
+}
+
+// Summarize counts entries per action and user, finds windows in which at
+// least threshold authentication failures happened, and the latest entry per
+// key.
+func Summarize(entries []Entry, window time.Duration, threshold int) Summary {
+	s := Summary{Entries: len(entries)}
+	actions, users := map[string]*Count{}, map[string]*Count{}
+	last := map[string]Entry{}
+	var failures []time.Time
+	for _, e := range entries {
+		t := e.Time()
+		if s.First.IsZero() || t.Before(s.First) {
+			s.First = t
+		}
+		if t.After(s.Last) {
+			s.Last = t
+		}
+		tally(actions, e.Action, e.Success)
+		name := e.User
+		if name == "" {
+			name = "(unknown)"
+		}
+		tally(users, name, e.Success)
+		if IsAuthFailure(e) {
+			failures = append(failures, t)
+		}
+		if e.Key != "" && contains(keyActions, e.Action) {
+			if prev, ok := last[e.Key]; !ok || !t.Before(prev.Time()) {
+				last[e.Key] = e
+			}
+		}
+	}
+	s.ByAction, s.ByUser = sorted(actions), sorted(users)
+	s.AuthFailures = len(failures)
+	s.Spikes = spikes(failures, window, threshold)
+	for k, e := range last {
+		s.LastAccess = append(s.LastAccess, Access{Key: k, Entry: e})
+	}
+	sort.Slice(s.LastAccess, func(i, j int) bool { return s.LastAccess[i].Key < s.LastAccess[j].Key })
+	return s
+}
+
+func tally(m map[string]*Count, name string, success bool) {
+	c, ok := m[name]
+	if !ok {
+		c = &Count{Name: name}
+		m[name] = c
+	}
+	c.Total++
+	if !success {
+		c.Failed++
+	}
+}
+
+func sorted(m map[string]*Count) []Count {
+	out := make([]Count, 0, len(m))
+	for _, c := range m {
+		out = append(out, *c)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Total != out[j].Total {
+			return out[i].Total > out[j].Total
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+// spikes merges overlapping windows that each start at a failure and hold at
+// least threshold failures.
+func spikes(times []time.Time, window time.Duration, threshold int) []Spike {
+	if threshold < 1 || window <= 0 {
+		return nil
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	var out []Spike
+	j := 0
+	for i := range times {
+		for j < len(times) && times[j].Sub(times[i]) < window {
+			j++
+		}
+		if j-i < threshold {
+			continue
+		}
+		if n := len(out); n > 0 && !times[i].After(out[n-1].End) {
+			out[n-1].End = times[j-1]
+			continue
+		}
+		out = append(out, Spike{Start: times[i], End: times[j-1]})
+	}
+	for k := range out {
+		for _, t := range times {
+			if !t.Before(out[k].Start) && !t.After(out[k].End) {
+				out[k].Failures++
+			}
+		}
+	}
+	return out
+}
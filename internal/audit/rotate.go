@@ -0,0 +1,66 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// rotateIfNeeded moves the log to path.1 (shifting older files up to
+// path.<maxFiles>) when appending n bytes would exceed maxSize, or when its
+// first entry is older than maxAge.
+func (s *fileSink) rotateIfNeeded(n int64) error {
+	if s.maxSize <= 0 && s.maxAge <= 0 {
+		return nil
+	}
+	fi, err := os.Stat(s.path)
+	if err != nil || fi.Size() == 0 {
+		return nil
+	}
+	due := s.maxSize > 0 && fi.Size()+n > s.maxSize
+	if !due && s.maxAge > 0 {
+		if first, ok := firstTimestamp(s.path); ok && time.Since(first) > s.maxAge {
+			due = true
+		}
+	}
+	if !due {
+		return nil
+	}
+	keep := s.maxFiles
+	if keep < 1 {
+		keep = 1
+	}
+	if err := os.Remove(rotatedName(s.path, keep)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for i := keep - 1; i >= 1; i-- {
+		if err := os.Rename(rotatedName(s.path, i), rotatedName(s.path, i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return os.Rename(s.path, rotatedName(s.path, 1))
+}
+
+func rotatedName(path string, i int) string {
+	return fmt.Sprintf("%s.%d", path, i)
+}
+
+func firstTimestamp(path string) (time.Time, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, false
+	}
+	defer f.Close()
+	line, err := bufio.NewReader(f).ReadBytes('\n')
+	if err != nil && len(line) == 0 {
+		return time.Time{}, false
+	}
+	var e Entry
+	if json.Unmarshal(line, &e) != nil {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339, e.Timestamp)
+	return t, err == nil
+}
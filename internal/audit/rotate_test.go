@@ -0,0 +1,123 @@
+package audit
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// entryLine is a log line of about 100 bytes with the given timestamp.
+func entryLine(at time.Time, n int) []byte {
+	line := fmt.Sprintf(`{"seq":%d,"timestamp":"%s","action":"set","key":"KEY"}`, n, at.UTC().Format(time.RFC3339))
+	return []byte(line + strings.Repeat(" ", 100-len(line)))
+}
+
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Count(string(data), "\n")
+}
+
+func TestFileSinkRotation(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name     string
+		sink     fileSink
+		first    time.Time // timestamp of the entries already in the log
+		existing int
+		writes   int
+		// lines in the log and in its rotated files, newest first
+		want []int
+	}{
+		{
+			name:   "no limits",
+			sink:   fileSink{},
+			first:  now.Add(-24 * time.Hour),
+			writes: 10,
+			want:   []int{10},
+		},
+		{
+			name:   "under max size",
+			sink:   fileSink{maxSize: 1000, maxFiles: 3},
+			first:  now,
+			writes: 9,
+			want:   []int{9},
+		},
+		{
+			name:   "max size reached",
+			sink:   fileSink{maxSize: 350, maxFiles: 3},
+			first:  now,
+			writes: 7,
+			want:   []int{1, 3, 3},
+		},
+		{
+			name:   "oldest rotated file is dropped",
+			sink:   fileSink{maxSize: 350, maxFiles: 2},
+			first:  now,
+			writes: 10,
+			want:   []int{1, 3, 3},
+		},
+		{
+			name:     "young log kept",
+			sink:     fileSink{maxAge: time.Hour, maxFiles: 3},
+			first:    now.Add(-time.Minute),
+			existing: 2,
+			writes:   1,
+			want:     []int{3},
+		},
+		{
+			name:     "old log rotated",
+			sink:     fileSink{maxAge: time.Hour, maxFiles: 3},
+			first:    now.Add(-2 * time.Hour),
+			existing: 2,
+			writes:   1,
+			want:     []int{1, 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.sink
+			s.path = filepath.Join(t.TempDir(), "audit.log")
+			var existing []byte
+			for i := range tt.existing {
+				existing = append(append(existing, entryLine(tt.first, i+1)...), '\n')
+			}
+			if err := os.WriteFile(s.path, existing, 0600); err != nil {
+				t.Fatal(err)
+			}
+			for i := range tt.writes {
+				at := tt.first
+				if tt.existing > 0 {
+					at = now
+				}
+				if err := s.write(nil, entryLine(at, tt.existing+i+1)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var got []int
+			for i := 0; ; i++ {
+				path := s.path
+				if i > 0 {
+					path = rotatedName(s.path, i)
+				}
+				n := countLines(t, path)
+				if n == 0 {
+					break
+				}
+				got = append(got, n)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("lines per file = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package audit
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/SrPlugin/GhostEnv/internal/config"
+)
+
+// sink is one destination for audit entries, selected by audit.output.
+type sink interface {
+	write(e *Entry, line []byte) error
+	// probe checks that the sink can be written without writing an entry.
+	probe() error
+	// headPath is where the chain head is kept.
+	headPath() string
+	// tip returns the sequence number and hash the next entry links to.
+	tip(key []byte) (uint64, string)
+}
+
+// stateSink keeps the chain of a sink that cannot be read back in a head
+// file under the audit key's directory.
+type stateSink struct {
+	path string
+}
+
+func (s stateSink) headPath() string { return s.path }
+
+func (s stateSink) tip(key []byte) (uint64, string) {
+	h, err := readHead(s.path, key)
+	if err != nil {
+		return 0, ""
+	}
+	return h.Seq, h.Hash
+}
+
+func newSink(cfg config.AuditConfig, vaultPath string) (sink, error) {
+	switch cfg.Output {
+	case "", "file":
+		maxSize, err := cfg.MaxSizeBytes()
+		if err != nil {
+			return nil, err
+		}
+		maxAge, err := cfg.MaxAgeDuration()
+		if err != nil {
+			return nil, err
+		}
+		return &fileSink{path: resolveLogPath(vaultPath), maxSize: maxSize, maxAge: maxAge, maxFiles: cfg.MaxFiles}, nil
+	case "stderr":
+		return stderrSink{stateFor(cfg)}, nil
+	case "syslog":
+		return &syslogSink{stateSink: stateFor(cfg), address: cfg.SyslogAddress}, nil
+	case "http":
+		timeout := 5 * time.Second
+		if cfg.WebhookTimeout != "" {
+			d, err := time.ParseDuration(cfg.WebhookTimeout)
+			if err != nil {
+				return nil, err
+			}
+			timeout = d
+		}
+		return &webhookSink{stateSink: stateFor(cfg), url: cfg.WebhookURL, timeout: timeout, tokenEnv: cfg.WebhookToken}, nil
+	}
+	return nil, fmt.Errorf("unknown audit output %q", cfg.Output)
+}
+
+func stateFor(cfg config.AuditConfig) stateSink {
+	return stateSink{path: filepath.Join(stateDir(cfg), "audit-"+cfg.Output+".head")}
+}
+
+type fileSink struct {
+	path     string
+	maxSize  int64
+	maxAge   time.Duration
+	maxFiles int
+}
+
+func (s *fileSink) open() (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(s.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+}
+
+func (s *fileSink) headPath() string { return s.path + ".head" }
+
+// tip reads the chain from the log itself, continuing from the newest
+// rotated file when the current one is empty.
+func (s *fileSink) tip(_ []byte) (uint64, string) {
+	line, _ := lastLine(s.path)
+	if len(line) == 0 {
+		line, _ = lastLine(rotatedName(s.path, 1))
+	}
+	return link(line)
+}
+
+func (s *fileSink) probe() error {
+	f, err := s.open()
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func (s *fileSink) write(_ *Entry, line []byte) error {
+	if err := s.rotateIfNeeded(int64(len(line) + 1)); err != nil {
+		return fmt.Errorf("rotate %s: %w", s.path, err)
+	}
+	f, err := s.open()
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+type stderrSink struct {
+	stateSink
+}
+
+func (stderrSink) probe() error { return nil }
+
+func (stderrSink) write(_ *Entry, line []byte) error {
+	_, err := fmt.Fprintln(os.Stderr, string(line))
+	return err
+}
+
+// webhookSink POSTs each entry as JSON. A bearer token, if any, is read from
+// the environment variable named by audit.webhook_token_env so it never sits
+// in the config file.
+type webhookSink struct {
+	stateSink
+	url      string
+	timeout  time.Duration
+	tokenEnv string
+}
+
+func (s *webhookSink) probe() error {
+	u, err := url.Parse(s.url)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid webhook URL %q", s.url)
+	}
+	return nil
+}
+
+func (s *webhookSink) write(_ *Entry, line []byte) error {
+	if err := s.probe(); err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(line))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.tokenEnv != "" {
+		if token := os.Getenv(s.tokenEnv); token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+	resp, err := (&http.Client{Timeout: s.timeout}).Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package audit
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+	"time"
+)
+
+// Local syslog sockets, tried in order when audit.syslog_address is unset.
+var syslogSockets = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// Facility authpriv (10): audit entries name keys and vault paths.
+const syslogFacility = 10
+
+// syslogSink writes RFC 3164 messages to the local syslog daemon over a Unix
+// socket, without the log/syslog package, which does not build on Windows.
+type syslogSink struct {
+	stateSink
+	address string
+}
+
+func (s *syslogSink) dial() (net.Conn, error) {
+	if runtime.GOOS == "windows" {
+		return nil, fmt.Errorf("syslog output is not supported on Windows")
+	}
+	addrs := syslogSockets
+	if s.address != "" {
+		addrs = []string{s.address}
+	}
+	var lastErr error
+	for _, addr := range addrs {
+		for _, network := range []string{"unixgram", "unix"} {
+			conn, err := net.DialTimeout(network, addr, 2*time.Second)
+			if err == nil {
+				return conn, nil
+			}
+			lastErr = err
+		}
+	}
+	return nil, fmt.Errorf("syslog: %w", lastErr)
+}
+
+func (s *syslogSink) probe() error {
+	conn, err := s.dial()
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+func (s *syslogSink) write(e *Entry, line []byte) error {
+	conn, err := s.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	severity := 6
+	switch e.Level {
+	case LevelDebug:
+		severity = 7
+	case LevelWarn:
+		severity = 4
+	}
+	msg := fmt.Sprintf("<%d>%s ghostenv[%d]: %s", syslogFacility*8+severity, time.Now().Format(time.Stamp), os.Getpid(), line)
+	if conn.RemoteAddr().Network() == "unix" {
+		msg += "\n"
+	}
+	if _, err := conn.Write([]byte(msg)); err != nil {
+		return fmt.Errorf("syslog: %w", err)
+	}
+	return nil
+}
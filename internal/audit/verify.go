@@ -0,0 +1,139 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LogFiles lists path and its rotated files (path.1, path.2, ...), oldest
+// first.
+func LogFiles(path string) []string {
+	matches, _ := filepath.Glob(path + ".*")
+	var rotated []int
+	for _, m := range matches {
+		if n, err := strconv.Atoi(strings.TrimPrefix(m, path+".")); err == nil && n > 0 {
+			rotated = append(rotated, n)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(rotated)))
+	var files []string
+	for _, n := range rotated {
+		files = append(files, rotatedName(path, n))
+	}
+	if _, err := os.Stat(path); err == nil {
+		files = append(files, path)
+	}
+	return files
+}
+
+// Report is the outcome of Verify. Problems are evidence of tampering or
+// loss; Notes are expected conditions worth knowing about.
+type Report struct {
+	Files    []string
+	Entries  int
+	Unsigned int
+	FirstSeq uint64
+	LastSeq  uint64
+	Problems []string
+	Notes    []string
+}
+
+func (r *Report) OK() bool { return len(r.Problems) == 0 }
+
+// Verify checks the chain across path and its rotated files: every signed
+// entry must carry a valid MAC, follow the previous sequence number and name
+// the hash of the line before it, and the chain must reach the head that
+// was recorded with the last write.
+func Verify(path string, key []byte) (*Report, error) {
+	r := &Report{Files: LogFiles(path)}
+	if len(r.Files) == 0 {
+		return nil, fmt.Errorf("no audit log at %s", path)
+	}
+	var prevSeq uint64
+	var prevHash string
+	for _, file := range r.Files {
+		if err := r.verifyFile(file, key, &prevSeq, &prevHash); err != nil {
+			return nil, err
+		}
+	}
+
+	h, err := readHead(path+".head", key)
+	switch {
+	case os.IsNotExist(err) && r.LastSeq > 0:
+		r.Problems = append(r.Problems, fmt.Sprintf("chain head %s is missing; the log holds signed entries up to seq %d, so the head was removed", filepath.Base(path)+".head", r.LastSeq))
+	case os.IsNotExist(err):
+		r.Notes = append(r.Notes, "no chain head recorded; truncation at the end cannot be detected")
+	case err != nil:
+		r.Problems = append(r.Problems, err.Error())
+	case h.Seq > r.LastSeq:
+		r.Problems = append(r.Problems, fmt.Sprintf("log truncated: last write was seq %d, log ends at seq %d", h.Seq, r.LastSeq))
+	case h.Seq < r.LastSeq:
+		r.Problems = append(r.Problems, fmt.Sprintf("entries after the recorded head (seq %d) were not written by ghostenv", h.Seq))
+	case h.Hash != prevHash:
+		r.Problems = append(r.Problems, fmt.Sprintf("last entry (seq %d) does not match the recorded head", h.Seq))
+	}
+	return r, nil
+}
+
+func (r *Report) verifyFile(file string, key []byte, prevSeq *uint64, prevHash *string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	defer f.Close()
+	name := filepath.Base(file)
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for n := 1; sc.Scan(); n++ {
+		line := sc.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		at := fmt.Sprintf("%s:%d", name, n)
+		r.Entries++
+		var e Entry
+		if err := json.Unmarshal(line, &e); err != nil {
+			r.Problems = append(r.Problems, fmt.Sprintf("%s: not a valid entry", at))
+			*prevHash = lineHash(line)
+			continue
+		}
+		switch {
+		case e.Seq == 0 && *prevSeq == 0:
+			r.Unsigned++
+		case e.Seq == 0:
+			r.Problems = append(r.Problems, fmt.Sprintf("%s: unsigned entry inside the chain", at))
+		case !validMAC(key, line):
+			r.Problems = append(r.Problems, fmt.Sprintf("%s: seq %d was modified (bad MAC)", at, e.Seq))
+		case *prevSeq == 0:
+			r.FirstSeq = e.Seq
+			if e.Seq > 1 {
+				r.Notes = append(r.Notes, fmt.Sprintf("chain starts at seq %d; earlier entries were rotated out or removed", e.Seq))
+			} else if e.Prev != *prevHash {
+				r.Problems = append(r.Problems, fmt.Sprintf("%s: seq 1 does not link to the entry before it", at))
+			}
+		case e.Seq <= *prevSeq:
+			r.Problems = append(r.Problems, fmt.Sprintf("%s: seq %d after seq %d (reordered or duplicated)", at, e.Seq, *prevSeq))
+		case e.Seq == *prevSeq+2:
+			r.Problems = append(r.Problems, fmt.Sprintf("%s: gap, seq %d missing", at, *prevSeq+1))
+		case e.Seq > *prevSeq+2:
+			r.Problems = append(r.Problems, fmt.Sprintf("%s: gap, seq %d to %d missing", at, *prevSeq+1, e.Seq-1))
+		case e.Prev != *prevHash:
+			r.Problems = append(r.Problems, fmt.Sprintf("%s: seq %d does not link to the previous entry (it was changed or replaced)", at, e.Seq))
+		}
+		if e.Seq > 0 {
+			*prevSeq = e.Seq
+			r.LastSeq = e.Seq
+		}
+		*prevHash = lineHash(line)
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	return nil
+}
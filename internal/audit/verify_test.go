@@ -0,0 +1,197 @@
+package audit
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SrPlugin/GhostEnv/internal/config"
+)
+
+// useConfig makes cfg the current config for the test, with the audit key
+// taken from the environment so nothing is written outside dir.
+func useConfig(t *testing.T, dir string, cfg config.AuditConfig) {
+	t.Helper()
+	enabled := true
+	cfg.Enabled = &enabled
+	if cfg.Output == "" {
+		cfg.Output = "file"
+	}
+	if cfg.LogLevel == "" {
+		cfg.LogLevel = LevelInfo
+	}
+	cfg.KeyFile = filepath.Join(dir, KeyFileName)
+	t.Setenv(SecretEnv, "test audit secret")
+	t.Setenv("GHOSTENV_AUDIT_LOG", "")
+	prev, prevRoot := config.Current(), config.ProjectRoot()
+	config.SetCurrent(&config.Config{Audit: cfg})
+	config.SetProjectRoot(dir)
+	t.Cleanup(func() {
+		config.SetCurrent(prev)
+		config.SetProjectRoot(prevRoot)
+	})
+}
+
+// writeLog logs one set per key to a fresh file and returns its path.
+func writeLog(t *testing.T, keys ...string) string {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.log")
+	useConfig(t, dir, config.AuditConfig{FilePath: path})
+	for _, k := range keys {
+		if err := Log(ActionSet, filepath.Join(dir, "dev.gev"), "dev", k, true, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+func readLines(t *testing.T, path string) [][]byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.Split(bytes.TrimRight(data, "\n"), []byte("\n"))
+}
+
+func writeLines(t *testing.T, path string, lines [][]byte) {
+	t.Helper()
+	data := append(bytes.Join(lines, []byte("\n")), '\n')
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name    string
+		tamper  func(t *testing.T, path string)
+		problem string
+	}{
+		{
+			name:   "intact",
+			tamper: func(t *testing.T, path string) {},
+		},
+		{
+			name: "tampered line",
+			tamper: func(t *testing.T, path string) {
+				lines := readLines(t, path)
+				lines[1] = bytes.Replace(lines[1], []byte(`"key":"B"`), []byte(`"key":"X"`), 1)
+				writeLines(t, path, lines)
+			},
+			problem: "audit.log:2: seq 2 was modified (bad MAC)",
+		},
+		{
+			name: "dropped line",
+			tamper: func(t *testing.T, path string) {
+				lines := readLines(t, path)
+				writeLines(t, path, append(lines[:1:1], lines[2:]...))
+			},
+			problem: "gap, seq 2 missing",
+		},
+		{
+			name: "reordered lines",
+			tamper: func(t *testing.T, path string) {
+				lines := readLines(t, path)
+				lines[1], lines[2] = lines[2], lines[1]
+				writeLines(t, path, lines)
+			},
+			problem: "seq 2 after seq 3",
+		},
+		{
+			name: "truncated file",
+			tamper: func(t *testing.T, path string) {
+				writeLines(t, path, readLines(t, path)[:2])
+			},
+			problem: "log truncated: last write was seq 3, log ends at seq 2",
+		},
+		{
+			name: "missing head",
+			tamper: func(t *testing.T, path string) {
+				if err := os.Remove(path + ".head"); err != nil {
+					t.Fatal(err)
+				}
+			},
+			problem: "chain head audit.log.head is missing",
+		},
+		{
+			name: "forged head",
+			tamper: func(t *testing.T, path string) {
+				if err := os.WriteFile(path+".head", []byte(`{"seq":2,"hash":"00","mac":"00"}`), 0600); err != nil {
+					t.Fatal(err)
+				}
+			},
+			problem: "has an invalid signature",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeLog(t, "A", "B", "C")
+			tt.tamper(t, path)
+			key, err := Key(false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, err := Verify(path, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.problem == "" {
+				if !r.OK() || r.Entries != 3 || r.LastSeq != 3 {
+					t.Fatalf("report = %+v, want 3 verified entries", r)
+				}
+				return
+			}
+			if r.OK() {
+				t.Fatalf("no problems reported, want %q", tt.problem)
+			}
+			if !strings.Contains(strings.Join(r.Problems, "\n"), tt.problem) {
+				t.Errorf("problems = %q, want one containing %q", r.Problems, tt.problem)
+			}
+		})
+	}
+}
+
+func TestVerifyWithWrongKey(t *testing.T) {
+	path := writeLog(t, "A")
+	t.Setenv(SecretEnv, "another secret")
+	key, err := Key(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := Verify(path, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.OK() {
+		t.Fatal("log verified under a different key")
+	}
+}
+
+func TestVerifyAcrossRotatedFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.log")
+	useConfig(t, dir, config.AuditConfig{FilePath: path, MaxSize: "1KB", MaxFiles: 10})
+	for range 20 {
+		if err := Log(ActionSet, "", "dev", "KEY", true, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	key, err := Key(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := Verify(path, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Files) < 2 {
+		t.Fatalf("files = %v, want the log to have rotated", r.Files)
+	}
+	if !r.OK() || r.FirstSeq != 1 || r.LastSeq != 20 {
+		t.Errorf("report = %+v, want seq 1 to 20 verified", r)
+	}
+}
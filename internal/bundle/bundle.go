@@ -0,0 +1,322 @@
+package bundle
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/SrPlugin/GhostEnv/internal/config"
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	Version = 1
+
+	ModePassphrase = "passphrase"
+	ModeRecipient  = "x25519"
+
+	hkdfInfo = "ghostenv bundle v1"
+)
+
+var (
+	ErrExpired             = errors.New("bundle has expired")
+	ErrPassphraseRequired  = errors.New("bundle is passphrase-protected: passphrase required")
+	ErrIdentityRequired    = errors.New("bundle is encrypted to a public key: identity required")
+	ErrDecryptionFailed    = errors.New("failed to decrypt bundle: wrong passphrase or identity, or the bundle was modified")
+	ErrUnsupportedVersion  = errors.New("unsupported bundle version")
+	ErrKeyListMismatch     = errors.New("bundle key list does not match its contents")
+	ErrNotForThisRecipient = errors.New("bundle was encrypted to a different public key")
+)
+
+// KDF records the Argon2id parameters used for a passphrase bundle, so the
+// recipient's own security.kdf settings do not matter.
+type KDF struct {
+	Salt    []byte `json:"salt"`
+	Time    uint32 `json:"time"`
+	Memory  uint32 `json:"memory"`
+	Threads uint8  `json:"threads"`
+}
+
+// Header is stored in the clear so a bundle can be inspected without the
+// secret, and authenticated as GCM additional data so none of it (expiry,
+// key list) can be changed without breaking decryption.
+type Header struct {
+	Version     int        `json:"version"`
+	Mode        string     `json:"mode"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	Environment string     `json:"environment,omitempty"`
+	Project     string     `json:"project,omitempty"`
+	Keys        []string   `json:"keys"`
+	KDF         *KDF       `json:"kdf,omitempty"`
+	Recipient   string     `json:"recipient,omitempty"`
+	Ephemeral   []byte     `json:"ephemeral,omitempty"`
+}
+
+type envelope struct {
+	Header     json.RawMessage `json:"header"`
+	Nonce      []byte          `json:"nonce"`
+	Ciphertext []byte          `json:"ciphertext"`
+}
+
+// Bundle is a parsed, still-encrypted bundle.
+type Bundle struct {
+	Header Header
+	raw    envelope
+	aad    []byte
+}
+
+// Meta describes a bundle being sealed. A zero ExpiresAt means no expiry.
+type Meta struct {
+	Environment string
+	Project     string
+	ExpiresAt   time.Time
+}
+
+func newHeader(mode string, secrets map[string]string, meta Meta) Header {
+	keys := make([]string, 0, len(secrets))
+	for k := range secrets {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	h := Header{
+		Version:     Version,
+		Mode:        mode,
+		CreatedAt:   time.Now().UTC().Truncate(time.Second),
+		Environment: meta.Environment,
+		Project:     meta.Project,
+		Keys:        keys,
+	}
+	if !meta.ExpiresAt.IsZero() {
+		exp := meta.ExpiresAt.UTC().Truncate(time.Second)
+		h.ExpiresAt = &exp
+	}
+	return h
+}
+
+// SealWithPassphrase encrypts secrets under a key derived from passphrase.
+func SealWithPassphrase(secrets map[string]string, meta Meta, passphrase []byte) ([]byte, error) {
+	h := newHeader(ModePassphrase, secrets, meta)
+	kdf := &KDF{
+		Salt:    make([]byte, config.SaltSize),
+		Time:    config.Argon2Time,
+		Memory:  config.Argon2Memory,
+		Threads: config.Argon2Threads,
+	}
+	if _, err := io.ReadFull(rand.Reader, kdf.Salt); err != nil {
+		return nil, err
+	}
+	h.KDF = kdf
+	key := kdf.derive(passphrase)
+	defer zeroBytes(key)
+	return seal(h, key, secrets)
+}
+
+// SealForRecipient encrypts secrets so only the holder of the identity
+// matching recipient can open them: an ephemeral X25519 key agreement, with
+// the shared secret run through HKDF-SHA256.
+func SealForRecipient(secrets map[string]string, meta Meta, recipient string) ([]byte, error) {
+	pub, err := ParseRecipient(recipient)
+	if err != nil {
+		return nil, err
+	}
+	eph, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	h := newHeader(ModeRecipient, secrets, meta)
+	h.Recipient = FormatRecipient(pub)
+	h.Ephemeral = eph.PublicKey().Bytes()
+	key, err := agreeKey(eph, pub, h.Ephemeral)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroBytes(key)
+	return seal(h, key, secrets)
+}
+
+func seal(h Header, key []byte, secrets map[string]string) ([]byte, error) {
+	aad, err := json.Marshal(h)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := json.Marshal(secrets)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroBytes(plaintext)
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	env := envelope{
+		Header:     aad,
+		Nonce:      nonce,
+		Ciphertext: gcm.Seal(nil, nonce, plaintext, aad),
+	}
+	out, err := json.MarshalIndent(env, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+// Parse reads a bundle's envelope and header without decrypting it.
+func Parse(data []byte) (*Bundle, error) {
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("not a ghostenv bundle: %w", err)
+	}
+	if len(env.Header) == 0 || len(env.Ciphertext) == 0 {
+		return nil, fmt.Errorf("not a ghostenv bundle: missing header or ciphertext")
+	}
+	var aad bytes.Buffer
+	if err := json.Compact(&aad, env.Header); err != nil {
+		return nil, fmt.Errorf("invalid bundle header: %w", err)
+	}
+	b := &Bundle{raw: env, aad: aad.Bytes()}
+	if err := json.Unmarshal(b.aad, &b.Header); err != nil {
+		return nil, fmt.Errorf("invalid bundle header: %w", err)
+	}
+	if b.Header.Version != Version {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, b.Header.Version)
+	}
+	if b.Header.KDF != nil {
+		if err := b.Header.KDF.check(); err != nil {
+			return nil, fmt.Errorf("invalid bundle header: %w", err)
+		}
+	}
+	return b, nil
+}
+
+func (b *Bundle) Expired(now time.Time) bool {
+	return b.Header.ExpiresAt != nil && now.After(*b.Header.ExpiresAt)
+}
+
+// Open decrypts the bundle. Pass the passphrase for passphrase bundles and
+// the identity for recipient bundles; the other may be nil. Expired bundles
+// are refused.
+func (b *Bundle) Open(passphrase []byte, identity *ecdh.PrivateKey) (map[string]string, error) {
+	if b.Expired(time.Now()) {
+		return nil, fmt.Errorf("%w (expired %s)", ErrExpired, b.Header.ExpiresAt.Local().Format(time.RFC3339))
+	}
+	var key []byte
+	switch b.Header.Mode {
+	case ModePassphrase:
+		if len(passphrase) == 0 {
+			return nil, ErrPassphraseRequired
+		}
+		if b.Header.KDF == nil {
+			return nil, fmt.Errorf("invalid bundle header: missing kdf")
+		}
+		key = b.Header.KDF.derive(passphrase)
+	case ModeRecipient:
+		if identity == nil {
+			return nil, ErrIdentityRequired
+		}
+		if b.Header.Recipient != FormatRecipient(identity.PublicKey()) {
+			return nil, ErrNotForThisRecipient
+		}
+		eph, err := ecdh.X25519().NewPublicKey(b.Header.Ephemeral)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bundle header: %w", err)
+		}
+		if key, err = agreeKey(identity, eph, b.Header.Ephemeral); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown bundle mode %q", b.Header.Mode)
+	}
+	defer zeroBytes(key)
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(b.raw.Nonce) != gcm.NonceSize() {
+		return nil, ErrDecryptionFailed
+	}
+	plaintext, err := gcm.Open(nil, b.raw.Nonce, b.raw.Ciphertext, b.aad)
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+	defer zeroBytes(plaintext)
+	var secrets map[string]string
+	if err := json.Unmarshal(plaintext, &secrets); err != nil {
+		return nil, fmt.Errorf("invalid bundle payload: %w", err)
+	}
+	if len(secrets) != len(b.Header.Keys) {
+		return nil, ErrKeyListMismatch
+	}
+	for _, k := range b.Header.Keys {
+		if _, ok := secrets[k]; !ok {
+			return nil, ErrKeyListMismatch
+		}
+	}
+	return secrets, nil
+}
+
+// maxKDFMemory caps the Argon2 memory a bundle may ask for, in KiB, so a
+// crafted header cannot make the importer allocate without bound.
+const maxKDFMemory = 1 << 20
+
+// check rejects KDF settings that would make Argon2 panic or allocate more
+// than maxKDFMemory. The header is read before anything is authenticated.
+func (k *KDF) check() error {
+	switch {
+	case len(k.Salt) != config.SaltSize:
+		return fmt.Errorf("kdf salt must be %d bytes, got %d", config.SaltSize, len(k.Salt))
+	case k.Time < 1:
+		return fmt.Errorf("kdf time must be at least 1")
+	case k.Threads < 1:
+		return fmt.Errorf("kdf threads must be at least 1")
+	case k.Memory < 8*uint32(k.Threads):
+		return fmt.Errorf("kdf memory %d KiB is below 8 KiB per thread", k.Memory)
+	case k.Memory > maxKDFMemory:
+		return fmt.Errorf("kdf memory %d KiB exceeds the %d KiB limit", k.Memory, maxKDFMemory)
+	}
+	return nil
+}
+
+func (k *KDF) derive(passphrase []byte) []byte {
+	return argon2.IDKey(passphrase, k.Salt, k.Time, k.Memory, k.Threads, config.KeySize)
+}
+
+// agreeKey derives the AES key from an X25519 exchange. The ephemeral public
+// key is bound in as the HKDF salt.
+func agreeKey(priv *ecdh.PrivateKey, pub *ecdh.PublicKey, ephemeral []byte) ([]byte, error) {
+	shared, err := priv.ECDH(pub)
+	if err != nil {
+		return nil, fmt.Errorf("key agreement failed: %w", err)
+	}
+	defer zeroBytes(shared)
+	return hkdf.Key(sha256.New, shared, ephemeral, hkdfInfo, config.KeySize)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func zeroBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
@@ -0,0 +1,89 @@
+package bundle
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// recipientPrefix marks a public key string so it cannot be confused with a
+// passphrase or another tool's key format.
+const recipientPrefix = "ghostenv-x25519:"
+
+const IdentityFileName = "bundle-identity.pem"
+
+func FormatRecipient(pub *ecdh.PublicKey) string {
+	return recipientPrefix + base64.RawURLEncoding.EncodeToString(pub.Bytes())
+}
+
+func ParseRecipient(s string) (*ecdh.PublicKey, error) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(s), recipientPrefix)
+	if !ok {
+		return nil, fmt.Errorf("invalid recipient: expected %s<key>", recipientPrefix)
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(rest)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipient: %w", err)
+	}
+	pub, err := ecdh.X25519().NewPublicKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipient: %w", err)
+	}
+	return pub, nil
+}
+
+// GenerateIdentity writes a new X25519 private key to path as PKCS#8 PEM
+// (mode 0600, never overwriting) and returns its recipient string.
+func GenerateIdentity(path string) (string, error) {
+	priv, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return "", err
+	}
+	defer zeroBytes(der)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", fmt.Errorf("failed to create identity directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", fmt.Errorf("failed to create identity file: %w", err)
+	}
+	if err := pem.Encode(f, &pem.Block{Type: "PRIVATE KEY", Bytes: der}); err != nil {
+		f.Close()
+		return "", fmt.Errorf("failed to write identity file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("failed to write identity file: %w", err)
+	}
+	return FormatRecipient(priv.PublicKey()), nil
+}
+
+func LoadIdentity(path string) (*ecdh.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read identity: %w", err)
+	}
+	defer zeroBytes(data)
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("invalid identity %s: expected a PEM PRIVATE KEY", path)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid identity %s: %w", path, err)
+	}
+	priv, ok := key.(*ecdh.PrivateKey)
+	if !ok || priv.Curve() != ecdh.X25519() {
+		return nil, fmt.Errorf("invalid identity %s: not an X25519 key", path)
+	}
+	return priv, nil
+}
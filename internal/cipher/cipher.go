@@ -19,8 +19,59 @@ var (
 	ErrEncryptionFailed   = errors.New("encryption failed")
 	ErrDecryptionFailed   = errors.New("decryption failed")
 	ErrVaultIntegrity     = errors.New("vault integrity check failed: file may be corrupted or tampered")
+	ErrPasswordRequired   = errors.New("password required: no cached key for this vault")
 )
 
+// KeyCache holds derived keys by salt so Argon2id does not have to run for
+// every command (see the agent package). Get and Put copy the key.
+type KeyCache interface {
+	Get(salt []byte) ([]byte, bool)
+	Put(salt, key []byte)
+}
+
+var keyCache KeyCache
+
+func SetKeyCache(c KeyCache) {
+	keyCache = c
+}
+
+// CurrentKeyCache returns the cache set by SetKeyCache, or nil.
+func CurrentKeyCache() KeyCache {
+	return keyCache
+}
+
+// CachedKey reports whether the key for salt is available without a password.
+func CachedKey(salt []byte) bool {
+	if keyCache == nil {
+		return false
+	}
+	key, ok := keyCache.Get(salt)
+	zeroBytes(key)
+	return ok
+}
+
+// Salt returns the salt stored at the start of encrypted vault data.
+func Salt(data []byte) ([]byte, error) {
+	if len(data) < config.SaltSize {
+		return nil, ErrInvalidVaultData
+	}
+	return data[:config.SaltSize], nil
+}
+
+// deriveOrCached uses the cached key when no password is given; an explicit
+// password always goes through Argon2id so a wrong one is never masked.
+func deriveOrCached(password, salt []byte) ([]byte, error) {
+	if len(password) == 0 {
+		if keyCache != nil {
+			if key, ok := keyCache.Get(salt); ok {
+				return key, nil
+			}
+		}
+		return nil, ErrPasswordRequired
+	}
+	return DeriveKey(password, salt), nil
+}
+
 func zeroBytes(b []byte) {
 	for i := range b {
 		b[i] = 0
@@ -32,9 +83,24 @@ func Encrypt(plaintext, password []byte) ([]byte, error) {
 	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
 		return nil, fmt.Errorf("%w: %v", ErrEncryptionFailed, err)
 	}
+	return EncryptWithSalt(plaintext, password, salt)
+}
 
-	key := DeriveKey(password, salt)
+// EncryptWithSalt encrypts under an existing salt. With an empty password the
+// key must come from the key cache; this is how vaults are re-saved while
+// unlocked through the agent without changing their key.
+func EncryptWithSalt(plaintext, password, salt []byte) ([]byte, error) {
+	if len(salt) != config.SaltSize {
+		return nil, fmt.Errorf("%w: invalid salt size", ErrEncryptionFailed)
+	}
+	key, err := deriveOrCached(password, salt)
+	if err != nil {
+		return nil, err
+	}
 	defer zeroBytes(key)
+	if len(password) > 0 && keyCache != nil {
+		keyCache.Put(salt, key)
+	}
 
 	block, err := aes.NewCipher(key)
 	if err != nil {
@@ -72,7 +138,10 @@ func Decrypt(data, password []byte) ([]byte, error) {
 	}
 
 	salt := data[:config.SaltSize]
-	key := DeriveKey(password, salt)
+	key, err := deriveOrCached(password, salt)
+	if err != nil {
+		return nil, err
+	}
 	defer zeroBytes(key)
 
 	if len(data) > config.SaltSize+config.HMACSize {
@@ -110,5 +179,8 @@ func Decrypt(data, password []byte) ([]byte, error) {
 		return nil, fmt.Errorf("%w: %v", ErrDecryptionFailed, err)
 	}
 
+	if len(password) > 0 && keyCache != nil {
+		keyCache.Put(salt, key)
+	}
 	return plaintext, nil
 }
@@ -1,12 +1,15 @@
 package config
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -54,11 +57,48 @@ func Default() *Config {
 	return c
 }
 
+// Load reads the global and project config files, merges them and validates
+// the result. Every problem found is reported in a *ValidationError. In a
+// multi-project file the project is picked as described at SelectProject.
 func Load(projectRoot string) (*Config, error) {
-	global := loadFile(globalConfigPath())
-	project := loadFile(projectConfigPath(projectRoot))
+	name, by := selectedProject()
+	return loadProject(projectRoot, name, by)
+}
+
+// LoadProject is Load for the project named name ("" to pick by directory).
+func LoadProject(projectRoot, name string) (*Config, error) {
+	return loadProject(projectRoot, name, "name")
+}
+
+func loadProject(projectRoot, name, by string) (*Config, error) {
+	path := projectConfigPath(projectRoot)
+	var global *Config
+	var gerr error
+	// In the home directory the legacy global file is the project file.
+	if gpath := globalConfigPath(); gpath != path {
+		global, gerr = loadFile(gpath)
+	}
+	docs, perr := loadDocuments(path)
+	ve := &ValidationError{}
+	ve.add(gerr)
+	ve.add(perr)
+	ve.add(checkProjects(docs))
+	if err := ve.orNil(); err != nil {
+		return nil, err
+	}
+	project, dir, err := selectDocument(docs, filepath.Dir(path), name)
+	if err != nil {
+		return nil, err
+	}
 
 	merged := merge(global, project)
+	merged.dir = dir
+	if len(docs) > 1 {
+		if name == "" {
+			by = "the working directory"
+		}
+		merged.selectedBy = by
+	}
 	applyDefaults(merged)
 	if err := validateAndParse(merged); err != nil {
 		return nil, err
@@ -66,6 +106,25 @@ func Load(projectRoot string) (*Config, error) {
 	return merged, nil
 }
 
+// SelectedBy says how the project of a multi-project file was chosen
+// ("--project", "GHOSTENV_PROJECT" or "the working directory"); "" for a
+// single-project file.
+func (c *Config) SelectedBy() string {
+	if c == nil {
+		return ""
+	}
+	return c.selectedBy
+}
+
+// ProjectDir is the directory of the selected project, from which relative
+// paths resolve; "" without a project file.
+func (c *Config) ProjectDir() string {
+	if c == nil {
+		return ""
+	}
+	return c.dir
+}
+
 func globalConfigPath() string {
 	home, err := os.UserHomeDir()
 	if err != nil {
@@ -90,19 +149,64 @@ func projectConfigPath(projectRoot string) string {
 	return filepath.Join(projectRoot, ProjectConfigName)
 }
 
-func loadFile(path string) *Config {
+// loadFile reads a config that holds a single document, such as the global
+// config.
+func loadFile(path string) (*Config, error) {
+	docs, err := loadDocuments(path)
+	switch {
+	case err != nil:
+		return nil, err
+	case len(docs) == 0:
+		return nil, nil
+	case len(docs) > 1:
+		o, _ := docs[1].Source("project")
+		return nil, &ValidationError{Problems: []Problem{{File: path, Line: o.Line,
+			Msg: "only project files may hold several documents"}}}
+	}
+	return docs[0], nil
+}
+
+// loadDocuments decodes every YAML document in path strictly: unknown fields
+// and values of the wrong type are errors. Empty documents are skipped and a
+// missing file is not an error.
+func loadDocuments(path string) ([]*Config, error) {
 	if path == "" {
-		return nil
+		return nil, nil
 	}
 	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
 	if err != nil {
-		return nil
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	var nodes []*yaml.Node
+	nodeDec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc yaml.Node
+		if err := nodeDec.Decode(&doc); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, yamlError(path, err)
+		}
+		nodes = append(nodes, &doc)
 	}
-	var c Config
-	if err := yaml.Unmarshal(data, &c); err != nil {
-		return nil
+
+	var docs []*Config
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	for _, doc := range nodes {
+		c := &Config{origins: make(origins), files: []string{path}}
+		if err := dec.Decode(c); err != nil {
+			return nil, yamlError(path, err)
+		}
+		if len(doc.Content) == 0 || doc.Content[0].Tag == "!!null" {
+			continue
+		}
+		c.origins.collect(path, doc, "")
+		docs = append(docs, c)
 	}
-	return &c
+	return docs, nil
 }
 
 func merge(global, project *Config) *Config {
@@ -113,6 +217,11 @@ func merge(global, project *Config) *Config {
 	if project == nil {
 		return out
 	}
+	var replaced []string
+	defer func() {
+		out.origins = overlay(out.origins, project.origins, replaced)
+		out.files = append(append([]string(nil), out.files...), project.files...)
+	}()
 	if project.Project.Name != "" {
 		out.Project.Name = project.Project.Name
 	}
@@ -122,14 +231,16 @@ func merge(global, project *Config) *Config {
 	if project.Project.DefaultEnv != "" {
 		out.Project.DefaultEnv = project.Project.DefaultEnv
 	}
+	out.Project.Path = project.Project.Path
 	if project.Storage.VaultDir != "" {
 		out.Storage.VaultDir = project.Storage.VaultDir
 	}
-	if project.Storage.RecursiveSearch {
-		out.Storage.RecursiveSearch = true
+	if project.Storage.RecursiveSearch != nil {
+		out.Storage.RecursiveSearch = project.Storage.RecursiveSearch
 	}
 	if project.Storage.AutoBackup.Path != "" {
 		out.Storage.AutoBackup = project.Storage.AutoBackup
+		replaced = append(replaced, "storage.auto_backup")
 	} else if project.Storage.AutoBackup.Enabled {
 		out.Storage.AutoBackup.Enabled = true
 		if project.Storage.AutoBackup.RetentionDays > 0 {
@@ -142,10 +253,12 @@ func merge(global, project *Config) *Config {
 		}
 		for k, v := range project.Storage.Environments {
 			out.Storage.Environments[k] = v
+			replaced = append(replaced, "storage.environments."+k)
 		}
 	}
 	if project.Security.Argon2.Memory != "" {
 		out.Security.Argon2 = project.Security.Argon2
+		replaced = append(replaced, "security.argon2")
 	}
 	if project.Security.Policy.MaxAuthAttempts > 0 {
 		out.Security.Policy.MaxAuthAttempts = project.Security.Policy.MaxAuthAttempts
@@ -156,14 +269,17 @@ func merge(global, project *Config) *Config {
 	if project.Security.Policy.DisallowPasswordFlagInProd {
 		out.Security.Policy.DisallowPasswordFlagInProd = true
 	}
-	if project.Microservices.Inheritance.SharedVault != "" {
+	if project.Microservices.Inheritance != (MicroInheritanceConfig{}) {
 		out.Microservices.Inheritance = project.Microservices.Inheritance
+		replaced = append(replaced, "microservices.inheritance")
 	}
 	if project.Microservices.Server.Host != "" || project.Microservices.Server.Port != 0 {
 		out.Microservices.Server = project.Microservices.Server
+		replaced = append(replaced, "microservices.server")
 	}
-	if project.Microservices.Postgres.Enabled {
+	if project.Microservices.Postgres != (PostgresConfig{}) {
 		out.Microservices.Postgres = project.Microservices.Postgres
+		replaced = append(replaced, "microservices.postgres")
 	}
 	if len(project.Scripts) > 0 {
 		if out.Scripts == nil {
@@ -173,18 +289,45 @@ func merge(global, project *Config) *Config {
 			out.Scripts[k] = v
 		}
 	}
-	if project.Audit.FilePath != "" {
-		out.Audit = project.Audit
-	} else if project.Audit.Enabled {
-		out.Audit.Enabled = true
-		if project.Audit.Output != "" {
-			out.Audit.Output = project.Audit.Output
+	if len(project.Processes) > 0 {
+		out.Processes = make(ProcessesConfig)
+		replaced = append(replaced, "processes")
+		for k, v := range project.Processes {
+			out.Processes[k] = v
 		}
-		if project.Audit.LogLevel != "" {
-			out.Audit.LogLevel = project.Audit.LogLevel
+	}
+	if len(project.Rotation) > 0 {
+		if out.Rotation == nil {
+			out.Rotation = make(RotationConfig)
+		}
+		for k, v := range project.Rotation {
+			out.Rotation[k] = v
+			replaced = append(replaced, "rotation."+k)
 		}
-		out.Audit.MaskKeys = project.Audit.MaskKeys
 	}
+	if len(project.Schema) > 0 {
+		if out.Schema == nil {
+			out.Schema = make(SchemaConfig)
+		}
+		for k, v := range project.Schema {
+			out.Schema[k] = v
+			replaced = append(replaced, "schema."+k)
+		}
+	}
+	if project.Validation.KeyPattern != "" {
+		out.Validation.KeyPattern = project.Validation.KeyPattern
+	}
+	if len(project.Validation.Prefixes) > 0 {
+		out.Validation.Prefixes = project.Validation.Prefixes
+		replaced = append(replaced, "validation.prefixes")
+	}
+	if len(project.Validation.Reserved) > 0 {
+		out.Validation.Reserved = append(append([]string(nil), out.Validation.Reserved...), project.Validation.Reserved...)
+	}
+	if project.Audit.WebhookURL != "" {
+		replaced = append(replaced, "audit.webhook_timeout", "audit.webhook_token_env")
+	}
+	mergeAudit(&out.Audit, project.Audit)
 	if project.Export.DefaultFormat != "" {
 		out.Export.DefaultFormat = project.Export.DefaultFormat
 	}
@@ -194,6 +337,47 @@ func merge(global, project *Config) *Config {
 	return out
 }
 
+func mergeAudit(out *AuditConfig, project AuditConfig) {
+	if project.Enabled != nil {
+		out.Enabled = project.Enabled
+	}
+	if project.Output != "" {
+		out.Output = project.Output
+	}
+	if project.FilePath != "" {
+		out.FilePath = project.FilePath
+	}
+	if project.LogLevel != "" {
+		out.LogLevel = project.LogLevel
+	}
+	if project.MaskKeys {
+		out.MaskKeys = true
+	}
+	if project.FailClosed {
+		out.FailClosed = true
+	}
+	if project.KeyFile != "" {
+		out.KeyFile = project.KeyFile
+	}
+	if project.MaxSize != "" {
+		out.MaxSize = project.MaxSize
+	}
+	if project.MaxAge != "" {
+		out.MaxAge = project.MaxAge
+	}
+	if project.MaxFiles != 0 {
+		out.MaxFiles = project.MaxFiles
+	}
+	if project.SyslogAddress != "" {
+		out.SyslogAddress = project.SyslogAddress
+	}
+	if project.WebhookURL != "" {
+		out.WebhookURL = project.WebhookURL
+		out.WebhookTimeout = project.WebhookTimeout
+		out.WebhookToken = project.WebhookToken
+	}
+}
+
 func applyDefaults(c *Config) {
 	if c.Project.DefaultEnv == "" {
 		c.Project.DefaultEnv = DefaultEnvironment
@@ -219,12 +403,15 @@ func applyDefaults(c *Config) {
 	if c.Audit.LogLevel == "" {
 		c.Audit.LogLevel = "info"
 	}
-	if c.Audit.FilePath == "" && c.Audit.Output == "" {
-		c.Audit.Enabled = true
+	if c.Audit.MaxFiles == 0 {
+		c.Audit.MaxFiles = 5
 	}
 	if c.Export.DefaultFormat == "" {
 		c.Export.DefaultFormat = "json"
 	}
+	if c.Microservices.Server.Host == "" {
+		c.Microservices.Server.Host = "127.0.0.1"
+	}
 	if c.Microservices.Server.Port == 0 {
 		c.Microservices.Server.Port = 8080
 	}
@@ -236,13 +423,37 @@ func applyDefaults(c *Config) {
 	}
 }
 
-func validateAndParse(c *Config) error {
-	if c.Security.Argon2.Memory != "" {
-		if _, err := parseMemoryToKB(c.Security.Argon2.Memory); err != nil {
-			return fmt.Errorf("config security.argon2.memory: %w", err)
+// MaxSizeBytes is the size at which the audit file is rotated; 0 means never.
+func (a AuditConfig) MaxSizeBytes() (int64, error) {
+	if a.MaxSize == "" {
+		return 0, nil
+	}
+	kb, err := parseMemoryToKB(a.MaxSize)
+	if err != nil {
+		return 0, err
+	}
+	return int64(kb) * 1024, nil
+}
+
+// MaxAgeDuration is the age of the oldest entry at which the audit file is
+// rotated; 0 means never.
+func (a AuditConfig) MaxAgeDuration() (time.Duration, error) {
+	if a.MaxAge == "" {
+		return 0, nil
+	}
+	return ParseAge(a.MaxAge)
+}
+
+// ParseAge parses a Go duration or a number of days ("30d").
+func ParseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid age %q", s)
 		}
+		return time.Duration(n) * 24 * time.Hour, nil
 	}
-	return nil
+	return time.ParseDuration(s)
 }
 
 func parseMemoryToKB(s string) (uint32, error) {
@@ -0,0 +1,194 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// A project file may hold several YAML documents, one per project of a
+// monorepo. Each project lives in its own directory below the file: its
+// project.path, or else a directory named after it. Relative paths in a
+// project's document, and so its vaults and audit log, resolve from there.
+
+// ProjectEnv selects the project like --project.
+const ProjectEnv = "GHOSTENV_PROJECT"
+
+var (
+	selected   string
+	selectedMu sync.RWMutex
+)
+
+// SelectProject picks the project Load uses in a multi-project file; empty
+// means GHOSTENV_PROJECT, else the project around the working directory.
+func SelectProject(name string) {
+	selectedMu.Lock()
+	defer selectedMu.Unlock()
+	selected = name
+}
+
+// selectedProject returns the requested project and where the name came
+// from.
+func selectedProject() (name, by string) {
+	selectedMu.RLock()
+	defer selectedMu.RUnlock()
+	if selected != "" {
+		return selected, "--project"
+	}
+	return os.Getenv(ProjectEnv), ProjectEnv
+}
+
+// Project is one document of the project file.
+type Project struct {
+	Name    string
+	Version string
+	Dir     string
+	File    string
+}
+
+// Projects lists the projects in the project file at root, in file order.
+// A single-document file yields one project whose directory is root.
+func Projects(root string) ([]Project, error) {
+	path := projectConfigPath(root)
+	docs, err := loadDocuments(path)
+	if err == nil {
+		err = checkProjects(docs)
+	}
+	if err != nil {
+		return nil, err
+	}
+	projects := make([]Project, len(docs))
+	for i, d := range docs {
+		projects[i] = Project{
+			Name:    d.Project.Name,
+			Version: d.Project.Version,
+			Dir:     projectDir(filepath.Dir(path), d, len(docs)),
+			File:    path,
+		}
+	}
+	return projects, nil
+}
+
+// projectDir is where doc's relative paths resolve from.
+func projectDir(root string, doc *Config, docs int) string {
+	switch {
+	case docs < 2 && doc.Project.Path == "":
+		return root
+	case doc.Project.Path != "":
+		if filepath.IsAbs(doc.Project.Path) {
+			return filepath.Clean(doc.Project.Path)
+		}
+		return filepath.Join(root, doc.Project.Path)
+	default:
+		return filepath.Join(root, doc.Project.Name)
+	}
+}
+
+// checkProjects reports documents that cannot be told apart.
+func checkProjects(docs []*Config) error {
+	if len(docs) < 2 {
+		return nil
+	}
+	ve := &ValidationError{}
+	seen := make(map[string]bool)
+	for _, d := range docs {
+		name := d.Project.Name
+		o, _ := d.Source("project.name")
+		switch {
+		case name == "":
+			ve.Problems = append(ve.Problems, Problem{File: d.files[0], Line: d.firstLine(), Path: "project.name",
+				Msg: "required when the file holds several projects"})
+		case seen[name]:
+			ve.Problems = append(ve.Problems, Problem{File: o.File, Line: o.Line, Path: "project.name",
+				Msg: fmt.Sprintf("project %q is defined twice", name)})
+		}
+		seen[name] = true
+		if strings.ContainsRune(d.Project.Path, 0) {
+			p, _ := d.Source("project.path")
+			ve.Problems = append(ve.Problems, Problem{File: p.File, Line: p.Line, Path: "project.path", Msg: "path contains a NUL byte"})
+		}
+	}
+	return ve.orNil()
+}
+
+// firstLine is the line of the document's first key.
+func (c *Config) firstLine() int {
+	line := 0
+	for _, o := range c.origins {
+		if line == 0 || o.Line < line {
+			line = o.Line
+		}
+	}
+	return line
+}
+
+// selectDocument picks the document named name, else the one whose
+// directory holds the working directory (the deepest, if they nest).
+func selectDocument(docs []*Config, root, name string) (*Config, string, error) {
+	if len(docs) == 0 {
+		if name != "" {
+			return nil, "", fmt.Errorf("no project %q: %s not found", name, projectConfigPath(root))
+		}
+		return nil, "", nil
+	}
+	if name != "" {
+		for _, d := range docs {
+			if d.Project.Name == name {
+				return d, projectDir(root, d, len(docs)), nil
+			}
+		}
+		return nil, "", fmt.Errorf("no project %q in %s (projects: %s)", name, projectConfigPath(root), projectNames(docs))
+	}
+	if len(docs) == 1 {
+		return docs[0], projectDir(root, docs[0], 1), nil
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, "", err
+	}
+	var best *Config
+	var bestDir string
+	for _, d := range docs {
+		dir := projectDir(root, d, len(docs))
+		if within(wd, dir) && len(dir) > len(bestDir) {
+			best, bestDir = d, dir
+		}
+	}
+	if best == nil {
+		return nil, "", fmt.Errorf("%s defines %d projects (%s); select one with --project or run ghostenv from its directory (see list-projects)",
+			projectConfigPath(root), len(docs), projectNames(docs))
+	}
+	return best, bestDir, nil
+}
+
+// ClaimsDir reports whether the project file in root is a multi-project file
+// with a project whose directory holds dir.
+func ClaimsDir(root, dir string) bool {
+	docs, err := loadDocuments(projectConfigPath(root))
+	if err != nil || len(docs) < 2 {
+		return false
+	}
+	for _, d := range docs {
+		if within(dir, projectDir(root, d, len(docs))) {
+			return true
+		}
+	}
+	return false
+}
+
+func within(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func projectNames(docs []*Config) string {
+	names := make([]string, len(docs))
+	for i, d := range docs {
+		names[i] = d.Project.Name
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
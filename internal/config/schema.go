@@ -2,27 +2,46 @@ package config
 
 type Config struct {
 	Project       ProjectConfig       `yaml:"project"`
-	Storage       StorageConfig      `yaml:"storage"`
-	Security      SecurityConfig     `yaml:"security"`
+	Storage       StorageConfig       `yaml:"storage"`
+	Security      SecurityConfig      `yaml:"security"`
 	Microservices MicroservicesConfig `yaml:"microservices"`
-	Scripts       ScriptsConfig      `yaml:"scripts"`
-	Audit         AuditConfig        `yaml:"audit"`
-	Export        ExportConfig       `yaml:"export"`
+	Scripts       ScriptsConfig       `yaml:"scripts"`
+	Audit         AuditConfig         `yaml:"audit"`
+	Export        ExportConfig        `yaml:"export"`
+	Processes     ProcessesConfig     `yaml:"processes"`
+	Rotation      RotationConfig      `yaml:"rotation"`
+	Schema        SchemaConfig        `yaml:"schema"`
+	Validation    ValidationConfig    `yaml:"validation"`
+
+	origins    origins
+	files      []string
+	dir        string
+	selectedBy string
 }
 
 type ProjectConfig struct {
 	Name       string `yaml:"name"`
 	Version    string `yaml:"version"`
 	DefaultEnv string `yaml:"default_env"`
+	Path       string `yaml:"path,omitempty"`
 }
 
+// StorageConfig locates the vaults. RecursiveSearch is a pointer so that
+// project detection keeps searching parent directories unless a config
+// turns it off.
 type StorageConfig struct {
-	VaultDir        string                    `yaml:"vault_dir"`
-	RecursiveSearch bool                      `yaml:"recursive_search"`
-	AutoBackup      AutoBackupConfig          `yaml:"auto_backup"`
-	Environments    map[string]EnvEntry       `yaml:"environments"`
+	VaultDir        string              `yaml:"vault_dir"`
+	RecursiveSearch *bool               `yaml:"recursive_search"`
+	AutoBackup      AutoBackupConfig    `yaml:"auto_backup"`
+	Environments    map[string]EnvEntry `yaml:"environments"`
+}
+
+func (s StorageConfig) IsRecursive() bool {
+	return s.RecursiveSearch == nil || *s.RecursiveSearch
 }
 
+// EnvEntry overrides where one environment's vault lives: Dir below
+// vault_dir, or Vault as an explicit file.
 type EnvEntry struct {
 	Dir   string `yaml:"dir,omitempty"`
 	Vault string `yaml:"vault,omitempty"`
@@ -63,9 +82,12 @@ type MicroInheritanceConfig struct {
 }
 
 type MicroServerConfig struct {
-	Host   string `yaml:"host"`
-	Port   int    `yaml:"port"`
-	UseTLS bool   `yaml:"use_tls"`
+	Host      string `yaml:"host"`
+	Port      int    `yaml:"port"`
+	UseTLS    bool   `yaml:"use_tls"`
+	CertFile  string `yaml:"cert_file,omitempty"`
+	KeyFile   string `yaml:"key_file,omitempty"`
+	RateLimit int    `yaml:"rate_limit,omitempty"`
 }
 
 type PostgresConfig struct {
@@ -80,15 +102,79 @@ type PostgresConfig struct {
 
 type ScriptsConfig map[string]string
 
+// AuditConfig selects where audit entries go. Auditing is off unless
+// enabled; Enabled is a pointer so that a project can also turn off what the
+// global config turned on.
 type AuditConfig struct {
-	Enabled  bool   `yaml:"enabled"`
-	Output   string `yaml:"output"`
-	FilePath string `yaml:"file_path"`
-	LogLevel string `yaml:"log_level"`
-	MaskKeys bool   `yaml:"mask_keys"`
+	Enabled        *bool  `yaml:"enabled"`
+	Output         string `yaml:"output"`
+	FilePath       string `yaml:"file_path"`
+	LogLevel       string `yaml:"log_level"`
+	MaskKeys       bool   `yaml:"mask_keys"`
+	KeyFile        string `yaml:"key_file,omitempty"`
+	FailClosed     bool   `yaml:"fail_closed"`
+	MaxSize        string `yaml:"max_size,omitempty"`
+	MaxAge         string `yaml:"max_age,omitempty"`
+	MaxFiles       int    `yaml:"max_files,omitempty"`
+	SyslogAddress  string `yaml:"syslog_address,omitempty"`
+	WebhookURL     string `yaml:"webhook_url,omitempty"`
+	WebhookTimeout string `yaml:"webhook_timeout,omitempty"`
+	WebhookToken   string `yaml:"webhook_token_env,omitempty"`
+}
+
+func (a AuditConfig) IsEnabled() bool {
+	return a.Enabled != nil && *a.Enabled
 }
 
 type ExportConfig struct {
 	DefaultFormat    string `yaml:"default_format"`
 	IncludeTimestamp bool   `yaml:"include_timestamp"`
 }
+
+type ProcessesConfig map[string]ProcessConfig
+
+type ProcessConfig struct {
+	Command string   `yaml:"command"`
+	Env     []string `yaml:"env,omitempty"`
+}
+
+// RotationConfig maps a vault key to the rotator that applies new values.
+type RotationConfig map[string]RotatorConfig
+
+type RotatorConfig struct {
+	Type    string `yaml:"type,omitempty"`
+	Command string `yaml:"command"`
+	Length  int    `yaml:"length,omitempty"`
+	Timeout string `yaml:"timeout,omitempty"`
+}
+
+// ValidationConfig is the key naming policy applied on top of the POSIX
+// portable rule when keys are set, imported or copied.
+type ValidationConfig struct {
+	KeyPattern string              `yaml:"key_pattern,omitempty"`
+	Prefixes   map[string][]string `yaml:"prefixes,omitempty"`
+	Reserved   []string            `yaml:"reserved,omitempty"`
+}
+
+// SchemaConfig declares the keys each environment needs, by environment name.
+// Rules under "*" apply to every environment.
+type SchemaConfig map[string]map[string]KeyRule
+
+type KeyRule struct {
+	Type     string `yaml:"type,omitempty"`
+	Pattern  string `yaml:"pattern,omitempty"`
+	Optional bool   `yaml:"optional,omitempty"`
+}
+
+// For returns the rules for env, with env-specific rules replacing the "*"
+// rule of the same key.
+func (s SchemaConfig) For(env string) map[string]KeyRule {
+	rules := make(map[string]KeyRule)
+	for k, r := range s["*"] {
+		rules[k] = r
+	}
+	for k, r := range s[env] {
+		rules[k] = r
+	}
+	return rules
+}
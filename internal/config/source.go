@@ -0,0 +1,85 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Origin is where a setting was written: a config file and the line of its
+// key.
+type Origin struct {
+	File string
+	Line int
+}
+
+func (o Origin) String() string {
+	return fmt.Sprintf("%s:%d", o.File, o.Line)
+}
+
+// origins maps dotted setting paths ("microservices.server.port") to where
+// they were set.
+type origins map[string]Origin
+
+// collect records every key under n, which is the document or mapping node
+// at prefix.
+func (o origins) collect(file string, n *yaml.Node, prefix string) {
+	if n.Kind == yaml.DocumentNode && len(n.Content) > 0 {
+		n = n.Content[0]
+	}
+	if n.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, value := n.Content[i], n.Content[i+1]
+		path := key.Value
+		if prefix != "" {
+			path = prefix + "." + key.Value
+		}
+		o[path] = Origin{File: file, Line: key.Line}
+		o.collect(file, value, path)
+	}
+}
+
+// overlay returns base with over on top. Settings under a prefix in replaced
+// were taken from over as a whole, so base's entries there are dropped.
+func overlay(base, over origins, replaced []string) origins {
+	out := make(origins, len(base)+len(over))
+	for path, o := range base {
+		if !under(path, replaced) {
+			out[path] = o
+		}
+	}
+	for path, o := range over {
+		out[path] = o
+	}
+	return out
+}
+
+func under(path string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if path == p || strings.HasPrefix(path, p+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Source reports which file and line set path; false means the value is a
+// default.
+func (c *Config) Source(path string) (Origin, bool) {
+	if c == nil {
+		return Origin{}, false
+	}
+	o, ok := c.origins[path]
+	return o, ok
+}
+
+// Files lists the config files that were read, global first.
+func (c *Config) Files() []string {
+	if c == nil {
+		return nil
+	}
+	return c.files
+}
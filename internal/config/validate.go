@@ -0,0 +1,290 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SrPlugin/GhostEnv/internal/validator"
+	"gopkg.in/yaml.v3"
+)
+
+// Problem is one invalid setting. File and Line are set when the setting
+// came from a config file.
+type Problem struct {
+	File string
+	Line int
+	Path string
+	Msg  string
+}
+
+func (p Problem) String() string {
+	var b strings.Builder
+	if p.File != "" {
+		b.WriteString(p.File)
+		if p.Line > 0 {
+			fmt.Fprintf(&b, ":%d", p.Line)
+		}
+		b.WriteString(": ")
+	}
+	if p.Path != "" {
+		b.WriteString(p.Path + ": ")
+	}
+	b.WriteString(p.Msg)
+	return b.String()
+}
+
+// ValidationError lists everything wrong with the config files, so that
+// they can be fixed in one go.
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Problems) == 1 {
+		return "invalid config: " + e.Problems[0].String()
+	}
+	lines := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		lines[i] = "  " + p.String()
+	}
+	return fmt.Sprintf("invalid config (%d problems):\n%s", len(e.Problems), strings.Join(lines, "\n"))
+}
+
+// add appends err's problems; errors other than ValidationError become a
+// problem of their own.
+func (e *ValidationError) add(err error) {
+	var ve *ValidationError
+	switch {
+	case err == nil:
+	case errors.As(err, &ve):
+		e.Problems = append(e.Problems, ve.Problems...)
+	default:
+		e.Problems = append(e.Problems, Problem{Msg: err.Error()})
+	}
+}
+
+func (e *ValidationError) orNil() error {
+	if len(e.Problems) == 0 {
+		return nil
+	}
+	return e
+}
+
+var (
+	yamlLine     = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+	unknownField = regexp.MustCompile(`^field (\S+) not found in type \S+$`)
+)
+
+// yamlError turns a yaml.v3 parse or decode error into problems located in
+// file.
+func yamlError(file string, err error) error {
+	msgs := []string{err.Error()}
+	var te *yaml.TypeError
+	if errors.As(err, &te) {
+		msgs = te.Errors
+	}
+	ve := &ValidationError{}
+	for _, m := range msgs {
+		p := Problem{File: file}
+		if g := yamlLine.FindStringSubmatch(m); g != nil {
+			p.Line, _ = strconv.Atoi(g[1])
+			m = g[2]
+		}
+		m = strings.TrimPrefix(m, "yaml: ")
+		if g := unknownField.FindStringSubmatch(m); g != nil {
+			m = fmt.Sprintf("unknown field %q", g[1])
+		}
+		p.Msg = m
+		ve.Problems = append(ve.Problems, p)
+	}
+	return ve
+}
+
+var envNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// SSLModes are the PostgreSQL sslmode values.
+var SSLModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+// checker collects problems, locating each at the line that set it.
+type checker struct {
+	c  *Config
+	ve ValidationError
+}
+
+func (k *checker) errorf(path, format string, args ...any) {
+	p := Problem{Path: path, Msg: fmt.Sprintf(format, args...)}
+	if o, ok := k.c.Source(path); ok {
+		p.File, p.Line = o.File, o.Line
+	}
+	k.ve.Problems = append(k.ve.Problems, p)
+}
+
+func (k *checker) envName(path, name string) {
+	if name != "" && !envNamePattern.MatchString(name) {
+		k.errorf(path, "invalid environment name %q (letters, digits, '_', '.' and '-', not starting with a symbol)", name)
+	}
+}
+
+func (k *checker) path(path, value string) {
+	if strings.ContainsRune(value, 0) {
+		k.errorf(path, "path contains a NUL byte")
+	}
+}
+
+// subdir checks a path that must stay below its parent directory.
+func (k *checker) subdir(path, value string) {
+	k.path(path, value)
+	if value == "" {
+		return
+	}
+	if filepath.IsAbs(value) || value == ".." || strings.HasPrefix(filepath.Clean(value), ".."+string(filepath.Separator)) {
+		k.errorf(path, "%q must be a relative path inside vault_dir", value)
+	}
+}
+
+func (k *checker) port(path string, port int) {
+	if port < 1 || port > 65535 {
+		k.errorf(path, "port %d out of range (1-65535)", port)
+	}
+}
+
+func (k *checker) nonNegative(path string, n int) {
+	if n < 0 {
+		k.errorf(path, "must not be negative, got %d", n)
+	}
+}
+
+func (k *checker) duration(path, value string) {
+	if value == "" {
+		return
+	}
+	if _, err := time.ParseDuration(value); err != nil {
+		k.errorf(path, "%v", err)
+	}
+}
+
+// validateAndParse checks the merged config after defaults are applied.
+func validateAndParse(c *Config) error {
+	k := &checker{c: c}
+
+	k.envName("project.default_env", c.Project.DefaultEnv)
+
+	k.path("storage.vault_dir", c.Storage.VaultDir)
+	k.path("storage.auto_backup.path", c.Storage.AutoBackup.Path)
+	k.nonNegative("storage.auto_backup.retention_days", c.Storage.AutoBackup.RetentionDays)
+	for env, e := range c.Storage.Environments {
+		prefix := "storage.environments." + env
+		k.envName(prefix, env)
+		k.subdir(prefix+".dir", e.Dir)
+		k.path(prefix+".vault", e.Vault)
+		if e.Dir != "" && e.Vault != "" {
+			k.errorf(prefix+".vault", "set either dir or vault, not both")
+		}
+	}
+
+	argon := c.Security.Argon2
+	if kb, err := parseMemoryToKB(argon.Memory); err != nil {
+		k.errorf("security.argon2.memory", "%v", err)
+	} else if kb < 8*uint32(argon.Parallelism) {
+		k.errorf("security.argon2.memory", "%s is below the Argon2 minimum of 8KB per lane (%d lanes)", argon.Memory, argon.Parallelism)
+	}
+	k.nonNegative("security.policy.max_auth_attempts", c.Security.Policy.MaxAuthAttempts)
+
+	ms := c.Microservices
+	if ms.Inheritance.Enabled && ms.Inheritance.SharedVault == "" {
+		k.errorf("microservices.inheritance.shared_vault", "required when inheritance is enabled")
+	}
+	k.path("microservices.inheritance.shared_vault", ms.Inheritance.SharedVault)
+	k.port("microservices.server.port", ms.Server.Port)
+	k.nonNegative("microservices.server.rate_limit", ms.Server.RateLimit)
+	if (ms.Server.CertFile == "") != (ms.Server.KeyFile == "") {
+		k.errorf("microservices.server.cert_file", "cert_file and key_file must be set together")
+	}
+	k.path("microservices.server.cert_file", ms.Server.CertFile)
+	k.path("microservices.server.key_file", ms.Server.KeyFile)
+	k.port("microservices.postgres.port", ms.Postgres.Port)
+	if !contains(SSLModes, ms.Postgres.SSLMode) {
+		k.errorf("microservices.postgres.ssl_mode", "unknown mode %q (use %s)", ms.Postgres.SSLMode, strings.Join(SSLModes, ", "))
+	}
+
+	for name, p := range c.Processes {
+		if strings.TrimSpace(p.Command) == "" {
+			k.errorf("processes."+name+".command", "command is required")
+		}
+	}
+	for key, r := range c.Rotation {
+		k.nonNegative("rotation."+key+".length", r.Length)
+		k.duration("rotation."+key+".timeout", r.Timeout)
+	}
+	for env, rules := range c.Schema {
+		if env != "*" {
+			k.envName("schema."+env, env)
+		}
+		for key, r := range rules {
+			if err := validator.Rule(r).Compile(); err != nil {
+				k.errorf("schema."+env+"."+key, "%v", err)
+			}
+		}
+	}
+	if c.Validation.KeyPattern != "" {
+		if _, err := regexp.Compile(c.Validation.KeyPattern); err != nil {
+			k.errorf("validation.key_pattern", "%v", err)
+		}
+	}
+	for env := range c.Validation.Prefixes {
+		if env != "*" {
+			k.envName("validation.prefixes."+env, env)
+		}
+	}
+	k.audit(c.Audit)
+	sort.SliceStable(k.ve.Problems, func(i, j int) bool {
+		a, b := k.ve.Problems[i], k.ve.Problems[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+	return k.ve.orNil()
+}
+
+func (k *checker) audit(a AuditConfig) {
+	switch a.Output {
+	case "file", "stderr", "syslog":
+	case "http":
+		if a.WebhookURL == "" {
+			k.errorf("audit.webhook_url", "required with output http")
+		}
+	default:
+		k.errorf("audit.output", "unknown sink %q (use file, stderr, syslog or http)", a.Output)
+	}
+	switch a.LogLevel {
+	case "debug", "info", "warn":
+	default:
+		k.errorf("audit.log_level", "unknown level %q (use debug, info or warn)", a.LogLevel)
+	}
+	k.path("audit.file_path", a.FilePath)
+	k.path("audit.key_file", a.KeyFile)
+	if _, err := a.MaxSizeBytes(); err != nil {
+		k.errorf("audit.max_size", "%v", err)
+	}
+	if _, err := a.MaxAgeDuration(); err != nil {
+		k.errorf("audit.max_age", "%v", err)
+	}
+	k.nonNegative("audit.max_files", a.MaxFiles)
+	k.duration("audit.webhook_timeout", a.WebhookTimeout)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,166 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// loadProjectYAML loads content as the only config file of a fresh project.
+func loadProjectYAML(t *testing.T, content string) (*Config, error) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("USERPROFILE", os.Getenv("HOME"))
+	t.Setenv("GHOSTENV_PROJECT", "")
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ProjectConfigName), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return Load(dir)
+}
+
+func TestLoadValid(t *testing.T) {
+	cfg, err := loadProjectYAML(t, `project:
+  name: api
+storage:
+  environments:
+    dev: { dir: dev }
+    prod: { vault: prod.gev }
+audit:
+  output: http
+  webhook_url: https://audit.example.com/hook
+  max_size: 10MB
+  max_age: 30d
+`)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Project.Name != "api" || cfg.Microservices.Postgres.Port != 5432 || cfg.Audit.LogLevel != "info" {
+		t.Errorf("config = %+v, want the file merged with the defaults", cfg)
+	}
+}
+
+func TestLoadValidationErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		// problems as printed, without the file name
+		want []string
+	}{
+		{
+			name:    "unknown field",
+			content: "project:\n  name: api\n  nmae: typo\n",
+			want:    []string{`:3: unknown field "nmae"`},
+		},
+		{
+			name:    "wrong type",
+			content: "microservices:\n  server:\n    port: eighty\n",
+			want:    []string{":3: cannot unmarshal !!str `eighty` into int"},
+		},
+		{
+			name:    "syntax error",
+			content: "project:\n  name: [api\n",
+			want:    []string{"did not find expected"},
+		},
+		{
+			name:    "port out of range",
+			content: "microservices:\n  server:\n    port: 70000\n",
+			want:    []string{":3: microservices.server.port: port 70000 out of range (1-65535)"},
+		},
+		{
+			name:    "environment dir outside vault_dir",
+			content: "storage:\n  environments:\n    dev: { dir: ../elsewhere }\n",
+			want:    []string{`storage.environments.dev.dir: "../elsewhere" must be a relative path inside vault_dir`},
+		},
+		{
+			name:    "dir and vault together",
+			content: "storage:\n  environments:\n    dev: { dir: dev, vault: dev.gev }\n",
+			want:    []string{"storage.environments.dev.vault: set either dir or vault, not both"},
+		},
+		{
+			name:    "invalid environment name",
+			content: "project:\n  default_env: \"-dev\"\n",
+			want:    []string{`:2: project.default_env: invalid environment name "-dev"`},
+		},
+		{
+			name:    "argon2 memory",
+			content: "security:\n  argon2:\n    memory: lots\n",
+			want:    []string{":3: security.argon2.memory: invalid memory"},
+		},
+		{
+			name:    "shared vault required",
+			content: "microservices:\n  inheritance:\n    enabled: true\n",
+			want:    []string{"microservices.inheritance.shared_vault: required when inheritance is enabled"},
+		},
+		{
+			name:    "unknown ssl mode",
+			content: "microservices:\n  postgres:\n    ssl_mode: sometimes\n",
+			want:    []string{`:3: microservices.postgres.ssl_mode: unknown mode "sometimes"`},
+		},
+		{
+			name:    "bad schema pattern",
+			content: "schema:\n  dev:\n    PORT: { pattern: \"[0-9\" }\n",
+			want:    []string{"schema.dev.PORT:"},
+		},
+		{
+			name:    "audit settings",
+			content: "audit:\n  output: kafka\n  log_level: loud\n  max_size: big\n  max_age: soon\n  max_files: -1\n",
+			want: []string{
+				`:2: audit.output: unknown sink "kafka"`,
+				`:3: audit.log_level: unknown level "loud"`,
+				":4: audit.max_size:",
+				":5: audit.max_age:",
+				":6: audit.max_files: must not be negative, got -1",
+			},
+		},
+		{
+			name:    "webhook url required",
+			content: "audit:\n  output: http\n",
+			want:    []string{"audit.webhook_url: required with output http"},
+		},
+		{
+			name:    "rotation timeout",
+			content: "rotation:\n  DB_PASS:\n    command: ./rotate.sh\n    timeout: forever\n",
+			want:    []string{":4: rotation.DB_PASS.timeout:"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadProjectYAML(t, tt.content)
+			var ve *ValidationError
+			if !errors.As(err, &ve) {
+				t.Fatalf("error = %v, want a *ValidationError", err)
+			}
+			if len(ve.Problems) != len(tt.want) {
+				t.Fatalf("problems = %q, want %d", ve.Problems, len(tt.want))
+			}
+			for i, p := range ve.Problems {
+				if !strings.Contains(p.String(), tt.want[i]) {
+					t.Errorf("problem %d = %q, want it to contain %q", i, p.String(), tt.want[i])
+				}
+				if p.File != "" && filepath.Base(p.File) != ProjectConfigName {
+					t.Errorf("problem %d is located in %s, want %s", i, p.File, ProjectConfigName)
+				}
+			}
+		})
+	}
+}
+
+func TestAuditIsEnabled(t *testing.T) {
+	on, off := true, false
+	tests := []struct {
+		enabled *bool
+		want    bool
+	}{
+		{nil, false},
+		{&on, true},
+		{&off, false},
+	}
+	for _, tt := range tests {
+		if got := (AuditConfig{Enabled: tt.enabled}).IsEnabled(); got != tt.want {
+			t.Errorf("IsEnabled with enabled=%v = %v, want %v", tt.enabled, got, tt.want)
+		}
+	}
+}
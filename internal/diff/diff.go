@@ -0,0 +1,99 @@
+package diff
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"sort"
+)
+
+type Kind string
+
+const (
+	Added   Kind = "added"
+	Removed Kind = "removed"
+	Changed Kind = "changed"
+)
+
+// Mode selects what counts as a changed value.
+type Mode string
+
+const (
+	// ModeHash compares SHA-256 digests of the values: any change counts.
+	ModeHash Mode = "hash"
+	// ModeLength only flags values whose length differs, for comparing
+	// against templates whose placeholders differ from the real values.
+	ModeLength Mode = "length"
+	// ModeKeys ignores values and reports only added and removed keys.
+	ModeKeys Mode = "keys"
+)
+
+func ParseMode(s string) (Mode, error) {
+	switch m := Mode(s); m {
+	case ModeHash, ModeLength, ModeKeys:
+		return m, nil
+	case "":
+		return ModeHash, nil
+	}
+	return "", fmt.Errorf("unknown comparison %q (use hash, length or keys)", s)
+}
+
+// Change is one differing key. Old is the value on the "from" side and New
+// on the "to" side; either is empty when the key is missing there.
+type Change struct {
+	Key  string
+	Kind Kind
+	Old  string
+	New  string
+}
+
+type Result struct {
+	Changes   []Change
+	Unchanged int
+}
+
+func (r Result) Empty() bool { return len(r.Changes) == 0 }
+
+func (r Result) Count(kind Kind) int {
+	n := 0
+	for _, c := range r.Changes {
+		if c.Kind == kind {
+			n++
+		}
+	}
+	return n
+}
+
+// Compare lists how to differs from from, sorted by key.
+func Compare(from, to map[string]string, mode Mode) Result {
+	var r Result
+	for k, old := range from {
+		v, ok := to[k]
+		switch {
+		case !ok:
+			r.Changes = append(r.Changes, Change{Key: k, Kind: Removed, Old: old})
+		case differs(old, v, mode):
+			r.Changes = append(r.Changes, Change{Key: k, Kind: Changed, Old: old, New: v})
+		default:
+			r.Unchanged++
+		}
+	}
+	for k, v := range to {
+		if _, ok := from[k]; !ok {
+			r.Changes = append(r.Changes, Change{Key: k, Kind: Added, New: v})
+		}
+	}
+	sort.Slice(r.Changes, func(i, j int) bool { return r.Changes[i].Key < r.Changes[j].Key })
+	return r
+}
+
+func differs(a, b string, mode Mode) bool {
+	switch mode {
+	case ModeKeys:
+		return false
+	case ModeLength:
+		return len(a) != len(b)
+	}
+	ha, hb := sha256.Sum256([]byte(a)), sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(ha[:], hb[:]) != 1
+}
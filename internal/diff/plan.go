@@ -0,0 +1,79 @@
+package diff
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+const PlanVersion = 1
+
+var ErrStalePlan = errors.New("plan is stale: a vault changed since it was created")
+
+// Plan is a reviewed promotion saved to disk. It holds no values: it pins
+// both vaults by fingerprint, so applying it is refused if either vault was
+// saved in between, and the changes are recomputed from the same state.
+type Plan struct {
+	Version         int          `json:"version"`
+	CreatedAt       time.Time    `json:"created_at"`
+	From            string       `json:"from"`
+	To              string       `json:"to"`
+	FromFingerprint string       `json:"from_fingerprint"`
+	ToFingerprint   string       `json:"to_fingerprint,omitempty"`
+	Patterns        []string     `json:"patterns,omitempty"`
+	Prune           bool         `json:"prune,omitempty"`
+	Changes         []PlanChange `json:"changes"`
+}
+
+type PlanChange struct {
+	Key  string `json:"key"`
+	Kind Kind   `json:"kind"`
+}
+
+func (p *Plan) Save(path string) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write plan: %w", err)
+	}
+	return nil
+}
+
+func LoadPlan(path string) (*Plan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan: %w", err)
+	}
+	var p Plan
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("invalid plan %s: %w", path, err)
+	}
+	if p.Version != PlanVersion {
+		return nil, fmt.Errorf("unsupported plan version %d", p.Version)
+	}
+	return &p, nil
+}
+
+// Verify checks that the vaults are still the ones the plan was made from and
+// that recomputing the changes gives the reviewed list.
+func (p *Plan) Verify(fromFingerprint, toFingerprint string, changes []Change) error {
+	if fromFingerprint != p.FromFingerprint {
+		return fmt.Errorf("%w (%s)", ErrStalePlan, p.From)
+	}
+	if toFingerprint != p.ToFingerprint {
+		return fmt.Errorf("%w (%s)", ErrStalePlan, p.To)
+	}
+	if len(changes) != len(p.Changes) {
+		return ErrStalePlan
+	}
+	for i, c := range changes {
+		if c.Key != p.Changes[i].Key || c.Kind != p.Changes[i].Kind {
+			return ErrStalePlan
+		}
+	}
+	return nil
+}
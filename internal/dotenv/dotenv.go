@@ -0,0 +1,195 @@
+package dotenv
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Entry is one assignment, in file order. Duplicate keys produce several
+// entries; callers decide which wins (usually the last).
+type Entry struct {
+	Key   string
+	Value string
+	Line  int
+}
+
+type Error struct {
+	Line int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+// Parse reads dotenv syntax:
+//
+//	KEY=value            unquoted; trailing " # comment" is dropped
+//	export KEY=value     the export prefix is ignored
+//	KEY='literal'        no escapes; may span lines
+//	KEY="a\nb"           escapes \n \r \t \" \\ \$; may span lines
+//	# comment            blank lines and comments are skipped
+func Parse(r io.Reader) ([]Entry, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return ParseString(string(data))
+}
+
+func ParseString(s string) ([]Entry, error) {
+	lines := strings.Split(strings.TrimPrefix(s, "\ufeff"), "\n")
+	for i := range lines {
+		lines[i] = strings.TrimSuffix(lines[i], "\r")
+	}
+
+	var entries []Entry
+	for i := 0; i < len(lines); i++ {
+		lineNo := i + 1
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if rest, ok := strings.CutPrefix(line, "export"); ok && rest != "" && (rest[0] == ' ' || rest[0] == '\t') {
+			line = strings.TrimSpace(rest)
+		}
+
+		rawKey, rest, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, &Error{Line: lineNo, Msg: "expected KEY=VALUE"}
+		}
+		key := strings.TrimSpace(rawKey)
+		if key == "" {
+			return nil, &Error{Line: lineNo, Msg: "missing key before '='"}
+		}
+		if strings.ContainsAny(key, " \t") {
+			return nil, &Error{Line: lineNo, Msg: fmt.Sprintf("invalid key %q: contains whitespace", key)}
+		}
+		rest = strings.TrimLeft(rest, " \t")
+
+		var value string
+		switch {
+		case strings.HasPrefix(rest, `"`):
+			v, next, err := readQuoted(lines, i, rest[1:], '"')
+			if err != nil {
+				return nil, err
+			}
+			value, i = v, next
+		case strings.HasPrefix(rest, `'`):
+			v, next, err := readQuoted(lines, i, rest[1:], '\'')
+			if err != nil {
+				return nil, err
+			}
+			value, i = v, next
+		default:
+			value = stripInlineComment(rest)
+		}
+		entries = append(entries, Entry{Key: key, Value: value, Line: lineNo})
+	}
+	return entries, nil
+}
+
+// readQuoted reads a quoted value starting after the opening quote on line
+// start, continuing onto following lines until the closing quote. It returns
+// the value and the index of the line holding the closing quote.
+func readQuoted(lines []string, start int, rest string, quote byte) (string, int, error) {
+	var b strings.Builder
+	i := start
+	s := rest
+	for {
+		for j := 0; j < len(s); j++ {
+			c := s[j]
+			if quote == '"' && c == '\\' && j+1 < len(s) {
+				j++
+				b.WriteString(unescape(s[j]))
+				continue
+			}
+			if c == quote {
+				tail := strings.TrimSpace(s[j+1:])
+				if tail != "" && !strings.HasPrefix(tail, "#") {
+					return "", 0, &Error{Line: i + 1, Msg: fmt.Sprintf("unexpected %q after closing quote", tail)}
+				}
+				return b.String(), i, nil
+			}
+			b.WriteByte(c)
+		}
+		i++
+		if i >= len(lines) {
+			kind := "double"
+			if quote == '\'' {
+				kind = "single"
+			}
+			return "", 0, &Error{Line: start + 1, Msg: fmt.Sprintf("unterminated %s-quoted value", kind)}
+		}
+		b.WriteByte('\n')
+		s = lines[i]
+	}
+}
+
+func unescape(c byte) string {
+	switch c {
+	case 'n':
+		return "\n"
+	case 'r':
+		return "\r"
+	case 't':
+		return "\t"
+	case '"', '\\', '$', '\'':
+		return string(c)
+	}
+	return "\\" + string(c)
+}
+
+func stripInlineComment(s string) string {
+	if strings.HasPrefix(s, "#") {
+		return ""
+	}
+	for i := 1; i < len(s); i++ {
+		if s[i] == '#' && (s[i-1] == ' ' || s[i-1] == '\t') {
+			s = s[:i]
+			break
+		}
+	}
+	return strings.TrimSpace(s)
+}
+
+// Quote renders value so that Parse reads it back unchanged: bare when it is
+// made of safe characters, double-quoted with escapes otherwise.
+func Quote(value string) string {
+	if isBare(value) {
+		return value
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '"', '\\', '$':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func isBare(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.IndexByte("_-.,:/@%+=", c) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
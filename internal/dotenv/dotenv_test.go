@@ -0,0 +1,99 @@
+package dotenv
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseString(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []Entry
+	}{
+		{"bare", "A=1\nB=two words\n", []Entry{{"A", "1", 1}, {"B", "two words", 2}}},
+		{"spaces around key and value", "  A =  1  \n", []Entry{{"A", "1", 1}}},
+		{"empty value", "A=\n", []Entry{{"A", "", 1}}},
+		{"export prefix", "export A=1\nexported=2\n", []Entry{{"A", "1", 1}, {"exported", "2", 2}}},
+		{"comments and blank lines", "# top\n\nA=1\n  # indented\n", []Entry{{"A", "1", 3}}},
+		{"inline comment", "A=value # note\nB=a#b\nC=# only\n", []Entry{{"A", "value", 1}, {"B", "a#b", 2}, {"C", "", 3}}},
+		{"single quotes are literal", `A='a\nb $X "q"'`, []Entry{{"A", `a\nb $X "q"`, 1}}},
+		{"double quote escapes", `A="a\nb\tc\r\"q\" \\ \$X \'"`, []Entry{{"A", "a\nb\tc\r\"q\" \\ $X '", 1}}},
+		{"unknown escape kept", `A="\d"`, []Entry{{"A", `\d`, 1}}},
+		{"hash inside quotes", `A="a # b" # note`, []Entry{{"A", "a # b", 1}}},
+		{"multi-line double quotes", "A=\"one\ntwo\"\nB=3\n", []Entry{{"A", "one\ntwo", 1}, {"B", "3", 3}}},
+		{"multi-line single quotes", "A='one\n\nthree'\n", []Entry{{"A", "one\n\nthree", 1}}},
+		{"CRLF line endings", "A=1\r\nB=\"x\"\r\n", []Entry{{"A", "1", 1}, {"B", "x", 2}}},
+		{"byte order mark", "\ufeffA=1\n", []Entry{{"A", "1", 1}}},
+		{"duplicates kept in order", "A=1\nA=2\n", []Entry{{"A", "1", 1}, {"A", "2", 2}}},
+		{"equals in value", "A=b=c\n", []Entry{{"A", "b=c", 1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseString(tt.input)
+			if err != nil {
+				t.Fatalf("ParseString: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("entries = %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("entry %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseStringErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		line  int
+		msg   string
+	}{
+		{"no equals", "A=1\nJUST_A_WORD\n", 2, "expected KEY=VALUE"},
+		{"missing key", "=1\n", 1, "missing key before '='"},
+		{"key with space", "MY KEY=1\n", 1, `invalid key "MY KEY": contains whitespace`},
+		{"unterminated double quote", "A=1\nB=\"open\nstill open\n", 2, "unterminated double-quoted value"},
+		{"unterminated single quote", "A='open\n", 1, "unterminated single-quoted value"},
+		{"text after closing quote", "A=\"x\" y\n", 1, `unexpected "y" after closing quote`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseString(tt.input)
+			var perr *Error
+			if !errors.As(err, &perr) {
+				t.Fatalf("error = %v, want a *Error", err)
+			}
+			if perr.Line != tt.line || perr.Msg != tt.msg {
+				t.Errorf("error = line %d %q, want line %d %q", perr.Line, perr.Msg, tt.line, tt.msg)
+			}
+		})
+	}
+}
+
+func TestQuoteRoundTrip(t *testing.T) {
+	values := []string{
+		"",
+		"plain",
+		"postgres://user@host:5432/db",
+		"two words",
+		"multi\nline\r\nvalue",
+		`quotes " and \ backslash`,
+		"$HOME and ${PATH}",
+		"tab\there",
+		"# not a comment",
+		"single ' quote",
+	}
+	for _, v := range values {
+		entries, err := ParseString("K=" + Quote(v) + "\n")
+		if err != nil {
+			t.Fatalf("Quote(%q) = %s does not parse: %v", v, Quote(v), err)
+		}
+		if len(entries) != 1 || entries[0].Value != v {
+			t.Errorf("Quote(%q) = %s parses back as %+v", v, Quote(v), entries)
+		}
+	}
+}
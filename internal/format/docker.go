@@ -0,0 +1,51 @@
+package format
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/SrPlugin/GhostEnv/internal/dotenv"
+)
+
+// dockerFormat is the docker run --env-file syntax: KEY=value with the value
+// taken literally to the end of the line. There is no quoting, so values
+// with line breaks cannot be represented.
+type dockerFormat struct{}
+
+func (dockerFormat) Name() string { return "docker" }
+
+func (dockerFormat) Encode(secrets map[string]string, opts Options) ([]byte, error) {
+	var b strings.Builder
+	b.WriteString(opts.Header.comment())
+	for _, k := range sortedKeys(secrets) {
+		v := secrets[k]
+		if strings.ContainsAny(v, "\r\n") {
+			return nil, fmt.Errorf("value for %s contains a line break, which docker env-files cannot represent", k)
+		}
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(v)
+		b.WriteByte('\n')
+	}
+	return []byte(b.String()), nil
+}
+
+func (dockerFormat) Decode(data []byte) ([]dotenv.Entry, error) {
+	lines := strings.Split(strings.TrimPrefix(string(data), "\ufeff"), "\n")
+	var entries []dotenv.Entry
+	for i, line := range lines {
+		line = strings.TrimLeft(strings.TrimSuffix(line, "\r"), " \t")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, &dotenv.Error{Line: i + 1, Msg: fmt.Sprintf("%s has no value (docker would copy it from the host environment)", line)}
+		}
+		if strings.ContainsAny(key, " \t") {
+			return nil, &dotenv.Error{Line: i + 1, Msg: fmt.Sprintf("invalid key %q: contains whitespace", key)}
+		}
+		entries = append(entries, dotenv.Entry{Key: key, Value: value, Line: i + 1})
+	}
+	return entries, nil
+}
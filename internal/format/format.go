@@ -0,0 +1,128 @@
+package format
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/SrPlugin/GhostEnv/internal/dotenv"
+)
+
+// Format converts between a secrets map and one file syntax. Decode returns
+// entries in file order; Line is 0 when the syntax does not track lines.
+type Format interface {
+	Name() string
+	Encode(secrets map[string]string, opts Options) ([]byte, error)
+	Decode(data []byte) ([]dotenv.Entry, error)
+}
+
+// Options carries settings that only some formats use.
+type Options struct {
+	// Name is the resource name for manifest formats (kubernetes).
+	Name string
+	// Header, when set, records the export's origin; see Header.
+	Header *Header
+}
+
+var (
+	registry   = map[string]Format{}
+	extensions = map[string]string{}
+)
+
+func register(f Format, aliases []string, exts []string) {
+	registry[f.Name()] = f
+	for _, a := range aliases {
+		registry[a] = f
+	}
+	for _, e := range exts {
+		extensions[e] = f.Name()
+	}
+}
+
+func init() {
+	register(jsonFormat{}, nil, []string{".json"})
+	register(yamlFormat{}, []string{"yml"}, []string{".yaml", ".yml"})
+	register(tomlFormat{}, nil, []string{".toml"})
+	register(envFormat{}, []string{"dotenv"}, []string{".env"})
+	register(shellFormat{}, []string{"sh"}, []string{".sh", ".bash"})
+	register(dockerFormat{}, nil, nil)
+	register(kubernetesFormat{}, []string{"k8s"}, nil)
+	register(githubFormat{}, nil, nil)
+}
+
+func Lookup(name string) (Format, error) {
+	f, ok := registry[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown format %q (available: %s)", name, strings.Join(Names(), ", "))
+	}
+	return f, nil
+}
+
+// Names lists the canonical format names, without aliases.
+func Names() []string {
+	var names []string
+	for key, f := range registry {
+		if key == f.Name() {
+			names = append(names, key)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FromPath picks a format from the file name: its extension, or the dotenv
+// convention of ".env" and ".env.<suffix>".
+func FromPath(path string) (Format, bool) {
+	base := strings.ToLower(filepath.Base(path))
+	if base == ".env" || strings.HasPrefix(base, ".env.") {
+		return registry["env"], true
+	}
+	name, ok := extensions[filepath.Ext(base)]
+	if !ok {
+		return nil, false
+	}
+	return registry[name], true
+}
+
+// Detect is FromPath for input files: a YAML file holding a Kubernetes
+// Secret is read as kubernetes. Unknown names fall back to dotenv.
+func Detect(path string, data []byte) Format {
+	f, ok := FromPath(path)
+	if !ok {
+		return registry["env"]
+	}
+	if f.Name() == "yaml" && isKubernetesSecret(data) {
+		return registry["kubernetes"]
+	}
+	return f
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type envFormat struct{}
+
+func (envFormat) Name() string { return "env" }
+
+func (envFormat) Encode(secrets map[string]string, opts Options) ([]byte, error) {
+	var b strings.Builder
+	b.WriteString(opts.Header.comment())
+	for _, k := range sortedKeys(secrets) {
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(dotenv.Quote(secrets[k]))
+		b.WriteByte('\n')
+	}
+	return []byte(b.String()), nil
+}
+
+func (envFormat) Decode(data []byte) ([]dotenv.Entry, error) {
+	return dotenv.ParseString(string(data))
+}
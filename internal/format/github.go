@@ -0,0 +1,90 @@
+package format
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/SrPlugin/GhostEnv/internal/dotenv"
+)
+
+const githubDelimiter = "GHOSTENV_EOF"
+
+// githubFormat writes the $GITHUB_ENV file syntax. Every value uses the
+// KEY<<DELIMITER heredoc form, so multi-line values are safe and a value can
+// never inject a second variable.
+type githubFormat struct{}
+
+func (githubFormat) Name() string { return "github" }
+
+func (githubFormat) Encode(secrets map[string]string, _ Options) ([]byte, error) {
+	var b strings.Builder
+	for _, k := range sortedKeys(secrets) {
+		v := secrets[k]
+		delim := githubHeredocDelimiter(v)
+		fmt.Fprintf(&b, "%s<<%s\n%s\n%s\n", k, delim, v, delim)
+	}
+	return []byte(b.String()), nil
+}
+
+// githubHeredocDelimiter returns a delimiter that does not occur as a line of v.
+func githubHeredocDelimiter(v string) string {
+	lines := strings.Split(v, "\n")
+	for n := 0; ; n++ {
+		delim := githubDelimiter
+		if n > 0 {
+			delim = fmt.Sprintf("%s_%d", githubDelimiter, n)
+		}
+		clash := false
+		for _, l := range lines {
+			if strings.TrimSuffix(l, "\r") == delim {
+				clash = true
+				break
+			}
+		}
+		if !clash {
+			return delim
+		}
+	}
+}
+
+func (githubFormat) Decode(data []byte) ([]dotenv.Entry, error) {
+	lines := strings.Split(strings.TrimPrefix(string(data), "\ufeff"), "\n")
+	for i := range lines {
+		lines[i] = strings.TrimSuffix(lines[i], "\r")
+	}
+	var entries []dotenv.Entry
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		start := i + 1
+		eq := strings.IndexByte(line, '=')
+		heredoc := strings.Index(line, "<<")
+		if heredoc > 0 && (eq < 0 || heredoc < eq) {
+			key, delim := line[:heredoc], line[heredoc+2:]
+			if delim == "" {
+				return nil, &dotenv.Error{Line: start, Msg: "missing heredoc delimiter"}
+			}
+			var body []string
+			closed := false
+			for i++; i < len(lines); i++ {
+				if lines[i] == delim {
+					closed = true
+					break
+				}
+				body = append(body, lines[i])
+			}
+			if !closed {
+				return nil, &dotenv.Error{Line: start, Msg: fmt.Sprintf("heredoc for %s is not closed with %s", key, delim)}
+			}
+			entries = append(entries, dotenv.Entry{Key: key, Value: strings.Join(body, "\n"), Line: start})
+			continue
+		}
+		if eq <= 0 {
+			return nil, &dotenv.Error{Line: start, Msg: "expected KEY=value or KEY<<DELIMITER"}
+		}
+		entries = append(entries, dotenv.Entry{Key: line[:eq], Value: line[eq+1:], Line: start})
+	}
+	return entries, nil
+}
@@ -0,0 +1,58 @@
+package format
+
+import (
+	"strings"
+	"time"
+)
+
+// metadataKey holds the export header in formats without comments (JSON).
+// Decoders drop it so a re-import does not see it as a secret.
+const metadataKey = "__ghostenv:export"
+
+// Header describes where an export came from. Formats that allow comments
+// write it as a leading comment block, JSON as a metadata field, Kubernetes
+// as annotations; the $GITHUB_ENV syntax has no room for it and omits it.
+type Header struct {
+	ExportedAt  time.Time
+	Environment string
+	Project     string
+	Fingerprint string
+}
+
+func (h *Header) fields() [][2]string {
+	fields := [][2]string{
+		{"exported_at", h.ExportedAt.UTC().Format(time.RFC3339)},
+		{"environment", h.Environment},
+	}
+	if h.Project != "" {
+		fields = append(fields, [2]string{"project", h.Project})
+	}
+	if h.Fingerprint != "" {
+		fields = append(fields, [2]string{"vault_fingerprint", h.Fingerprint})
+	}
+	return fields
+}
+
+func (h *Header) comment() string {
+	if h == nil {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("# Exported by ghostenv\n")
+	for _, f := range h.fields() {
+		b.WriteString("# ")
+		b.WriteString(f[0])
+		b.WriteString(": ")
+		b.WriteString(f[1])
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+func (h *Header) metadata() map[string]string {
+	m := make(map[string]string)
+	for _, f := range h.fields() {
+		m[f[0]] = f[1]
+	}
+	return m
+}
@@ -0,0 +1,61 @@
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/SrPlugin/GhostEnv/internal/dotenv"
+)
+
+type jsonFormat struct{}
+
+func (jsonFormat) Name() string { return "json" }
+
+func (jsonFormat) Encode(secrets map[string]string, opts Options) ([]byte, error) {
+	var v interface{} = secrets
+	if opts.Header != nil {
+		m := make(map[string]interface{}, len(secrets)+1)
+		for k, s := range secrets {
+			m[k] = s
+		}
+		m[metadataKey] = opts.Header.metadata()
+		v = m
+	}
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal secrets: %w", err)
+	}
+	return append(out, '\n'), nil
+}
+
+func (jsonFormat) Decode(data []byte) ([]dotenv.Entry, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var raw map[string]interface{}
+	if err := dec.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	delete(raw, metadataKey)
+	values := make(map[string]string, len(raw))
+	for k, v := range raw {
+		switch v := v.(type) {
+		case string:
+			values[k] = v
+		case json.Number:
+			values[k] = v.String()
+		case bool:
+			values[k] = strconv.FormatBool(v)
+		case nil:
+			values[k] = ""
+		default:
+			return nil, fmt.Errorf("value for %s must be a string, number or boolean", k)
+		}
+	}
+	entries := make([]dotenv.Entry, 0, len(values))
+	for _, k := range sortedKeys(values) {
+		entries = append(entries, dotenv.Entry{Key: k, Value: values[k]})
+	}
+	return entries, nil
+}
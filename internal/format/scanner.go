@@ -0,0 +1,88 @@
+package format
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/SrPlugin/GhostEnv/internal/dotenv"
+)
+
+// scanner is a byte cursor with line tracking for the formats whose values
+// can span lines (shell, TOML).
+type scanner struct {
+	s    string
+	pos  int
+	line int
+}
+
+func newScanner(data []byte) *scanner {
+	s := strings.TrimPrefix(string(data), "\ufeff")
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return &scanner{s: s, line: 1}
+}
+
+func (sc *scanner) eof() bool { return sc.pos >= len(sc.s) }
+
+func (sc *scanner) peek() byte {
+	if sc.eof() {
+		return 0
+	}
+	return sc.s[sc.pos]
+}
+
+func (sc *scanner) next() byte {
+	c := sc.s[sc.pos]
+	sc.pos++
+	if c == '\n' {
+		sc.line++
+	}
+	return c
+}
+
+func (sc *scanner) hasPrefix(p string) bool {
+	return strings.HasPrefix(sc.s[sc.pos:], p)
+}
+
+func (sc *scanner) skip(n int) {
+	for i := 0; i < n && !sc.eof(); i++ {
+		sc.next()
+	}
+}
+
+// skipBlank skips spaces and tabs, not newlines.
+func (sc *scanner) skipBlank() {
+	for !sc.eof() && (sc.peek() == ' ' || sc.peek() == '\t') {
+		sc.pos++
+	}
+}
+
+func (sc *scanner) skipLine() {
+	for !sc.eof() && sc.next() != '\n' {
+	}
+}
+
+// endOfLine accepts trailing blanks and an optional # comment.
+func (sc *scanner) endOfLine() error {
+	sc.skipBlank()
+	switch sc.peek() {
+	case 0, '\n':
+		sc.skipLine()
+		return nil
+	case '#':
+		sc.skipLine()
+		return nil
+	}
+	return sc.errorf("unexpected %q", sc.restOfLine())
+}
+
+func (sc *scanner) restOfLine() string {
+	rest := sc.s[sc.pos:]
+	if i := strings.IndexByte(rest, '\n'); i >= 0 {
+		rest = rest[:i]
+	}
+	return rest
+}
+
+func (sc *scanner) errorf(msg string, args ...interface{}) error {
+	return &dotenv.Error{Line: sc.line, Msg: fmt.Sprintf(msg, args...)}
+}
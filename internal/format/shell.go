@@ -0,0 +1,179 @@
+package format
+
+import (
+	"strings"
+
+	"github.com/SrPlugin/GhostEnv/internal/dotenv"
+)
+
+type shellFormat struct{}
+
+func (shellFormat) Name() string { return "shell" }
+
+// Encode writes export KEY='value' lines. Single quotes are the only shell
+// quoting with no special characters; an embedded quote closes the string,
+// adds an escaped quote and reopens it.
+func (shellFormat) Encode(secrets map[string]string, opts Options) ([]byte, error) {
+	var b strings.Builder
+	b.WriteString(opts.Header.comment())
+	for _, k := range sortedKeys(secrets) {
+		b.WriteString("export ")
+		b.WriteString(k)
+		b.WriteString("='")
+		b.WriteString(strings.ReplaceAll(secrets[k], "'", `'\''`))
+		b.WriteString("'\n")
+	}
+	return []byte(b.String()), nil
+}
+
+// Decode reads assignments the way a POSIX shell would, without expansion:
+// an unquoted or double-quoted $ is rejected rather than silently kept.
+func (shellFormat) Decode(data []byte) ([]dotenv.Entry, error) {
+	sc := newScanner(data)
+	var entries []dotenv.Entry
+	for {
+		for !sc.eof() && strings.IndexByte(" \t\n;", sc.peek()) >= 0 {
+			sc.next()
+		}
+		if sc.eof() {
+			return entries, nil
+		}
+		if sc.peek() == '#' {
+			sc.skipLine()
+			continue
+		}
+		line := sc.line
+		if sc.hasPrefix("export ") || sc.hasPrefix("export\t") {
+			sc.skip(len("export"))
+			sc.skipBlank()
+		}
+		start := sc.pos
+		for !sc.eof() && strings.IndexByte("= \t\n", sc.peek()) < 0 {
+			sc.next()
+		}
+		key := sc.s[start:sc.pos]
+		if sc.peek() != '=' || key == "" {
+			return nil, sc.errorf("expected KEY=VALUE")
+		}
+		sc.next()
+		value, err := readShellWord(sc)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, dotenv.Entry{Key: key, Value: value, Line: line})
+		sc.skipBlank()
+		if sc.peek() == ';' {
+			sc.next()
+			continue
+		}
+		if err := sc.endOfLine(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func readShellWord(sc *scanner) (string, error) {
+	var b strings.Builder
+	for !sc.eof() {
+		c := sc.peek()
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == ';':
+			return b.String(), nil
+		case c == '\'':
+			line := sc.line
+			sc.next()
+			end := strings.IndexByte(sc.s[sc.pos:], '\'')
+			if end < 0 {
+				sc.line = line
+				return "", sc.errorf("unterminated single-quoted value")
+			}
+			for i := 0; i < end; i++ {
+				b.WriteByte(sc.next())
+			}
+			sc.next()
+		case c == '"':
+			if err := readShellDouble(sc, &b); err != nil {
+				return "", err
+			}
+		case c == '\\':
+			sc.next()
+			if sc.eof() {
+				return b.String(), nil
+			}
+			if n := sc.next(); n != '\n' {
+				b.WriteByte(n)
+			}
+		case c == '$' && sc.hasPrefix("$'"):
+			if err := readShellANSI(sc, &b); err != nil {
+				return "", err
+			}
+		case c == '$' || c == '`':
+			return "", sc.errorf("%q expansion is not supported; quote the value with single quotes", c)
+		default:
+			b.WriteByte(sc.next())
+		}
+	}
+	return b.String(), nil
+}
+
+func readShellDouble(sc *scanner, b *strings.Builder) error {
+	line := sc.line
+	sc.next()
+	for !sc.eof() {
+		c := sc.next()
+		switch c {
+		case '"':
+			return nil
+		case '\\':
+			if sc.eof() {
+				break
+			}
+			switch n := sc.next(); n {
+			case '$', '`', '"', '\\':
+				b.WriteByte(n)
+			case '\n':
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(n)
+			}
+		case '$', '`':
+			return sc.errorf("%q expansion is not supported; quote the value with single quotes", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	sc.line = line
+	return sc.errorf("unterminated double-quoted value")
+}
+
+// readShellANSI reads bash $'...' strings, which other tools emit for values
+// with control characters.
+func readShellANSI(sc *scanner, b *strings.Builder) error {
+	line := sc.line
+	sc.skip(2)
+	for !sc.eof() {
+		c := sc.next()
+		if c == '\'' {
+			return nil
+		}
+		if c != '\\' || sc.eof() {
+			b.WriteByte(c)
+			continue
+		}
+		switch n := sc.next(); n {
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case '\\', '\'', '"':
+			b.WriteByte(n)
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(n)
+		}
+	}
+	sc.line = line
+	return sc.errorf("unterminated $'...' value")
+}
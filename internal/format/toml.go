@@ -0,0 +1,267 @@
+package format
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/SrPlugin/GhostEnv/internal/dotenv"
+)
+
+// tomlFormat handles the flat subset of TOML that maps to secrets: top-level
+// key = value pairs. Tables and arrays are rejected rather than flattened.
+type tomlFormat struct{}
+
+func (tomlFormat) Name() string { return "toml" }
+
+func (tomlFormat) Encode(secrets map[string]string, opts Options) ([]byte, error) {
+	var b strings.Builder
+	b.WriteString(opts.Header.comment())
+	for _, k := range sortedKeys(secrets) {
+		if isTOMLBareKey(k) {
+			b.WriteString(k)
+		} else {
+			b.WriteString(tomlQuote(k))
+		}
+		b.WriteString(" = ")
+		b.WriteString(tomlQuote(secrets[k]))
+		b.WriteByte('\n')
+	}
+	return []byte(b.String()), nil
+}
+
+func (tomlFormat) Decode(data []byte) ([]dotenv.Entry, error) {
+	sc := newScanner(data)
+	var entries []dotenv.Entry
+	for {
+		for !sc.eof() && strings.IndexByte(" \t\n", sc.peek()) >= 0 {
+			sc.next()
+		}
+		if sc.eof() {
+			return entries, nil
+		}
+		switch sc.peek() {
+		case '#':
+			sc.skipLine()
+			continue
+		case '[':
+			return nil, sc.errorf("tables are not supported; use top-level key = value pairs")
+		}
+		line := sc.line
+		key, err := readTOMLKey(sc)
+		if err != nil {
+			return nil, err
+		}
+		sc.skipBlank()
+		if sc.peek() != '=' {
+			if sc.peek() == '.' {
+				return nil, sc.errorf("dotted keys are not supported")
+			}
+			return nil, sc.errorf("expected '=' after key %s", key)
+		}
+		sc.next()
+		sc.skipBlank()
+		value, err := readTOMLValue(sc)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, dotenv.Entry{Key: key, Value: value, Line: line})
+		if err := sc.endOfLine(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func readTOMLKey(sc *scanner) (string, error) {
+	switch sc.peek() {
+	case '"':
+		sc.next()
+		return readTOMLBasic(sc, false)
+	case '\'':
+		sc.next()
+		return readTOMLLiteral(sc, false)
+	}
+	start := sc.pos
+	for !sc.eof() && isTOMLBareByte(sc.peek()) {
+		sc.next()
+	}
+	if sc.pos == start {
+		return "", sc.errorf("expected a key")
+	}
+	return sc.s[start:sc.pos], nil
+}
+
+func readTOMLValue(sc *scanner) (string, error) {
+	switch {
+	case sc.hasPrefix(`"""`):
+		sc.skip(3)
+		trimLeadingNewline(sc)
+		return readTOMLBasic(sc, true)
+	case sc.hasPrefix(`'''`):
+		sc.skip(3)
+		trimLeadingNewline(sc)
+		return readTOMLLiteral(sc, true)
+	case sc.peek() == '"':
+		sc.next()
+		return readTOMLBasic(sc, false)
+	case sc.peek() == '\'':
+		sc.next()
+		return readTOMLLiteral(sc, false)
+	case sc.peek() == '[' || sc.peek() == '{':
+		return "", sc.errorf("arrays and inline tables are not supported")
+	}
+	// Numbers, booleans and dates are kept as written.
+	raw := sc.restOfLine()
+	if i := strings.IndexByte(raw, '#'); i >= 0 {
+		raw = raw[:i]
+	}
+	raw = strings.TrimRight(raw, " \t")
+	if raw == "" {
+		return "", sc.errorf("missing value")
+	}
+	sc.pos += len(raw)
+	return raw, nil
+}
+
+func trimLeadingNewline(sc *scanner) {
+	if sc.peek() == '\n' {
+		sc.next()
+	}
+}
+
+func readTOMLLiteral(sc *scanner, multiline bool) (string, error) {
+	line := sc.line
+	var b strings.Builder
+	for !sc.eof() {
+		if multiline && sc.hasPrefix(`'''`) {
+			sc.skip(3)
+			return b.String(), nil
+		}
+		c := sc.peek()
+		if !multiline && c == '\'' {
+			sc.next()
+			return b.String(), nil
+		}
+		if !multiline && c == '\n' {
+			break
+		}
+		b.WriteByte(sc.next())
+	}
+	sc.line = line
+	return "", sc.errorf("unterminated string")
+}
+
+func readTOMLBasic(sc *scanner, multiline bool) (string, error) {
+	line := sc.line
+	var b strings.Builder
+	for !sc.eof() {
+		if multiline && sc.hasPrefix(`"""`) {
+			sc.skip(3)
+			return b.String(), nil
+		}
+		c := sc.peek()
+		if !multiline && c == '"' {
+			sc.next()
+			return b.String(), nil
+		}
+		if !multiline && c == '\n' {
+			break
+		}
+		sc.next()
+		if c != '\\' {
+			b.WriteByte(c)
+			continue
+		}
+		if sc.eof() {
+			break
+		}
+		switch n := sc.next(); n {
+		case 'b':
+			b.WriteByte('\b')
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'f':
+			b.WriteByte('\f')
+		case 'r':
+			b.WriteByte('\r')
+		case '"', '\\':
+			b.WriteByte(n)
+		case 'u', 'U':
+			size := 4
+			if n == 'U' {
+				size = 8
+			}
+			if len(sc.s)-sc.pos < size {
+				return "", sc.errorf("invalid unicode escape")
+			}
+			code, err := strconv.ParseUint(sc.s[sc.pos:sc.pos+size], 16, 32)
+			if err != nil {
+				return "", sc.errorf("invalid unicode escape")
+			}
+			sc.pos += size
+			b.WriteRune(rune(code))
+		case ' ', '\t', '\n':
+			// Line-ending backslash: drop all whitespace up to the next
+			// non-blank character.
+			if !multiline {
+				return "", sc.errorf("invalid escape")
+			}
+			for !sc.eof() && strings.IndexByte(" \t\n", sc.peek()) >= 0 {
+				sc.next()
+			}
+		default:
+			return "", sc.errorf("invalid escape \\%c", n)
+		}
+	}
+	sc.line = line
+	return "", sc.errorf("unterminated string")
+}
+
+func tomlQuote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\f':
+			b.WriteString(`\f`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func isTOMLBareKey(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isTOMLBareByte(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isTOMLBareByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
@@ -0,0 +1,163 @@
+package format
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/SrPlugin/GhostEnv/internal/dotenv"
+	"gopkg.in/yaml.v3"
+)
+
+type yamlFormat struct{}
+
+func (yamlFormat) Name() string { return "yaml" }
+
+func (yamlFormat) Encode(secrets map[string]string, opts Options) ([]byte, error) {
+	out, err := marshalYAML(secrets)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(opts.Header.comment()), out...), nil
+}
+
+// Decode walks the node tree rather than unmarshalling into a map so values
+// keep their literal text (0123 stays "0123") and entries keep line numbers.
+func (yamlFormat) Decode(data []byte) ([]dotenv.Entry, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid YAML: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, &dotenv.Error{Line: root.Line, Msg: "expected a mapping of KEY: value"}
+	}
+	var entries []dotenv.Entry
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		k, v := root.Content[i], root.Content[i+1]
+		if v.Kind != yaml.ScalarNode {
+			return nil, &dotenv.Error{Line: v.Line, Msg: fmt.Sprintf("value for %s must be a scalar", k.Value)}
+		}
+		value := v.Value
+		if v.Tag == "!!null" {
+			value = ""
+		}
+		entries = append(entries, dotenv.Entry{Key: k.Value, Value: value, Line: k.Line})
+	}
+	return entries, nil
+}
+
+func marshalYAML(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(v); err != nil {
+		return nil, fmt.Errorf("failed to marshal YAML: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("failed to marshal YAML: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+type kubernetesSecret struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   kubernetesMeta    `yaml:"metadata"`
+	Type       string            `yaml:"type,omitempty"`
+	Data       map[string]string `yaml:"data"`
+	StringData map[string]string `yaml:"stringData,omitempty"`
+}
+
+type kubernetesMeta struct {
+	Name        string            `yaml:"name"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+type kubernetesFormat struct{}
+
+func (kubernetesFormat) Name() string { return "kubernetes" }
+
+func (kubernetesFormat) Encode(secrets map[string]string, opts Options) ([]byte, error) {
+	s := kubernetesSecret{
+		APIVersion: "v1",
+		Kind:       "Secret",
+		Metadata:   kubernetesMeta{Name: kubernetesName(opts.Name)},
+		Type:       "Opaque",
+		Data:       make(map[string]string, len(secrets)),
+	}
+	if opts.Header != nil {
+		s.Metadata.Annotations = make(map[string]string)
+		for _, f := range opts.Header.fields() {
+			s.Metadata.Annotations["ghostenv/"+strings.ReplaceAll(f[0], "_", "-")] = f[1]
+		}
+	}
+	for k, v := range secrets {
+		s.Data[k] = base64.StdEncoding.EncodeToString([]byte(v))
+	}
+	return marshalYAML(s)
+}
+
+// Decode reads data (base64) and stringData (plain). As in the API server,
+// stringData wins when a key is in both.
+func (kubernetesFormat) Decode(data []byte) ([]dotenv.Entry, error) {
+	var s kubernetesSecret
+	if err := yaml.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("invalid YAML: %w", err)
+	}
+	if s.Kind != "Secret" {
+		return nil, fmt.Errorf("expected kind: Secret, got %q", s.Kind)
+	}
+	values := make(map[string]string, len(s.Data)+len(s.StringData))
+	for k, v := range s.Data {
+		raw, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return nil, fmt.Errorf("data.%s is not valid base64: %w", k, err)
+		}
+		values[k] = string(raw)
+	}
+	for k, v := range s.StringData {
+		values[k] = v
+	}
+	entries := make([]dotenv.Entry, 0, len(values))
+	for _, k := range sortedKeys(values) {
+		entries = append(entries, dotenv.Entry{Key: k, Value: values[k]})
+	}
+	return entries, nil
+}
+
+func isKubernetesSecret(data []byte) bool {
+	var head struct {
+		APIVersion string `yaml:"apiVersion"`
+		Kind       string `yaml:"kind"`
+	}
+	if err := yaml.Unmarshal(data, &head); err != nil {
+		return false
+	}
+	return head.APIVersion != "" && head.Kind == "Secret"
+}
+
+// kubernetesName turns name into a valid DNS-1123 subdomain.
+func kubernetesName(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '.':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('-')
+		}
+	}
+	out := strings.Trim(b.String(), "-.")
+	if len(out) > 253 {
+		out = strings.Trim(out[:253], "-.")
+	}
+	if out == "" {
+		return "ghostenv"
+	}
+	return out
+}
@@ -0,0 +1,176 @@
+package generate
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	_ "embed"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const (
+	TypeHex        = "hex"
+	TypeBase64     = "base64"
+	TypeUUID       = "uuid"
+	TypeAlnum      = "alnum"
+	TypePassphrase = "passphrase"
+	TypeRSA2048    = "rsa-2048"
+	TypeEd25519    = "ed25519"
+)
+
+const (
+	DefaultBytes = 32
+	DefaultChars = 32
+	DefaultWords = 6
+	alnum        = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+)
+
+// BIP-39 English wordlist: 2048 words, 11 bits of entropy each.
+//
+//go:embed wordlist.txt
+var wordlistData string
+
+var wordlist = strings.Fields(wordlistData)
+
+// Options selects what to generate. Length is characters for alnum, bytes of
+// entropy for hex and base64, and words for passphrase; it is ignored for
+// uuid and key types. Charset replaces the alnum alphabet.
+type Options struct {
+	Type    string
+	Length  int
+	Charset string
+}
+
+func Types() []string {
+	return []string{TypeHex, TypeBase64, TypeUUID, TypeAlnum, TypePassphrase, TypeRSA2048, TypeEd25519}
+}
+
+func Generate(opts Options) (string, error) {
+	if opts.Length < 0 {
+		return "", fmt.Errorf("length must be positive, got %d", opts.Length)
+	}
+	if opts.Charset != "" && opts.Type != "" && opts.Type != TypeAlnum {
+		return "", fmt.Errorf("--charset only applies to type %s", TypeAlnum)
+	}
+	switch opts.Type {
+	case TypeHex:
+		b, err := randomBytes(orDefault(opts.Length, DefaultBytes))
+		if err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(b), nil
+	case TypeBase64:
+		b, err := randomBytes(orDefault(opts.Length, DefaultBytes))
+		if err != nil {
+			return "", err
+		}
+		return base64.StdEncoding.EncodeToString(b), nil
+	case TypeUUID:
+		return uuid()
+	case "", TypeAlnum:
+		charset := alnum
+		if opts.Charset != "" {
+			charset = opts.Charset
+		}
+		return fromCharset(charset, orDefault(opts.Length, DefaultChars))
+	case TypePassphrase:
+		return passphrase(orDefault(opts.Length, DefaultWords))
+	case TypeRSA2048:
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return "", err
+		}
+		return encodePKCS8(key)
+	case TypeEd25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return "", err
+		}
+		return encodePKCS8(key)
+	}
+	return "", fmt.Errorf("unknown type %q (valid: %s)", opts.Type, strings.Join(Types(), ", "))
+}
+
+func orDefault(n, def int) int {
+	if n == 0 {
+		return def
+	}
+	return n
+}
+
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func randomIndex(n int) (int, error) {
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
+	}
+	return int(i.Int64()), nil
+}
+
+func fromCharset(charset string, length int) (string, error) {
+	// Each character is drawn with the same odds, however often it appears
+	// in charset.
+	var chars []rune
+	seen := make(map[rune]bool)
+	for _, c := range charset {
+		if !seen[c] {
+			seen[c] = true
+			chars = append(chars, c)
+		}
+	}
+	if len(chars) < 2 {
+		return "", fmt.Errorf("charset needs at least 2 distinct characters")
+	}
+	out := make([]rune, length)
+	for i := range out {
+		idx, err := randomIndex(len(chars))
+		if err != nil {
+			return "", err
+		}
+		out[i] = chars[idx]
+	}
+	return string(out), nil
+}
+
+func passphrase(words int) (string, error) {
+	out := make([]string, words)
+	for i := range out {
+		idx, err := randomIndex(len(wordlist))
+		if err != nil {
+			return "", err
+		}
+		out[i] = wordlist[idx]
+	}
+	return strings.Join(out, "-"), nil
+}
+
+func uuid() (string, error) {
+	b, err := randomBytes(16)
+	if err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+func encodePKCS8(key interface{}) (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), nil
+}
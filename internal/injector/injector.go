@@ -1,12 +1,29 @@
 package injector
 
 import (
+	"io"
 	"os"
 	"os/exec"
+	"runtime"
+	"time"
 )
 
 type Runner interface {
 	Run(command string, args []string, secrets map[string]string) error
+	Start(command string, args []string, secrets map[string]string, opts StartOptions) (*Process, error)
+}
+
+// StartOptions redirects a started child's output. Nil writers default to
+// the parent's stdout and stderr. Stdin is only attached when Interactive is
+// set, so several children never compete for the terminal. Group starts the
+// child in its own process group so that Stop also reaches its descendants,
+// e.g. everything a ShellCommand line starts; it is meant for children that
+// do not read the terminal.
+type StartOptions struct {
+	Stdout      io.Writer
+	Stderr      io.Writer
+	Interactive bool
+	Group       bool
 }
 
 type runner struct{}
@@ -16,6 +33,36 @@ func NewRunner() Runner {
 }
 
 func (r *runner) Run(command string, args []string, secrets map[string]string) error {
+	cmd := newCommand(command, args, secrets)
+	return cmd.Run()
+}
+
+func (r *runner) Start(command string, args []string, secrets map[string]string, opts StartOptions) (*Process, error) {
+	cmd := newCommand(command, args, secrets)
+	if opts.Stdout != nil {
+		cmd.Stdout = opts.Stdout
+	}
+	if opts.Stderr != nil {
+		cmd.Stderr = opts.Stderr
+	}
+	if !opts.Interactive {
+		cmd.Stdin = nil
+	}
+	if opts.Group {
+		setGroup(cmd)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	p := &Process{cmd: cmd, group: opts.Group, done: make(chan struct{})}
+	go func() {
+		p.err = cmd.Wait()
+		close(p.done)
+	}()
+	return p, nil
+}
+
+func newCommand(command string, args []string, secrets map[string]string) *exec.Cmd {
 	cmd := exec.Command(command, args...)
 
 	env := os.Environ()
@@ -27,8 +74,58 @@ func (r *runner) Run(command string, args []string, secrets map[string]string) e
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
+	return cmd
+}
 
-	return cmd.Run()
+// Process is a child started by Runner.Start.
+type Process struct {
+	cmd   *exec.Cmd
+	group bool
+	done  chan struct{}
+	err   error
+}
+
+func (p *Process) Pid() int {
+	return p.cmd.Process.Pid
+}
+
+// Done is closed once the child has exited.
+func (p *Process) Done() <-chan struct{} {
+	return p.done
+}
+
+func (p *Process) Wait() error {
+	<-p.done
+	return p.err
+}
+
+// Stop asks the child to terminate and kills it if it is still running
+// after grace. It returns once the child has exited. A child started with
+// Group is signalled together with its process group.
+func (p *Process) Stop(grace time.Duration) error {
+	select {
+	case <-p.done:
+		return p.err
+	default:
+	}
+	if err := terminate(p.cmd.Process, p.group); err != nil {
+		_ = kill(p.cmd.Process, p.group)
+	}
+	select {
+	case <-p.done:
+	case <-time.After(grace):
+		_ = kill(p.cmd.Process, p.group)
+		<-p.done
+	}
+	return p.err
+}
+
+// ShellCommand wraps a command line (e.g. from a Procfile) for the platform shell.
+func ShellCommand(line string) (string, []string) {
+	if runtime.GOOS == "windows" {
+		return "cmd", []string{"/C", line}
+	}
+	return "/bin/sh", []string{"-c", line}
 }
 
 func Run(command string, args []string, secrets map[string]string) error {
@@ -0,0 +1,64 @@
+package injector
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// PrefixWriter writes each complete line to the underlying writer with a
+// prefix. Writers created from the same PrefixGroup share a lock so lines
+// from different processes never interleave.
+type PrefixWriter struct {
+	group  *PrefixGroup
+	prefix []byte
+	buf    []byte
+}
+
+type PrefixGroup struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewPrefixGroup(w io.Writer) *PrefixGroup {
+	return &PrefixGroup{w: w}
+}
+
+func (g *PrefixGroup) Writer(prefix string) *PrefixWriter {
+	return &PrefixWriter{group: g, prefix: []byte(prefix)}
+}
+
+func (p *PrefixWriter) Write(b []byte) (int, error) {
+	p.buf = append(p.buf, b...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			break
+		}
+		if err := p.emit(p.buf[:i+1]); err != nil {
+			return 0, err
+		}
+		p.buf = p.buf[i+1:]
+	}
+	return len(b), nil
+}
+
+// Flush writes any trailing partial line.
+func (p *PrefixWriter) Flush() error {
+	if len(p.buf) == 0 {
+		return nil
+	}
+	line := append(p.buf, '\n')
+	p.buf = nil
+	return p.emit(line)
+}
+
+func (p *PrefixWriter) emit(line []byte) error {
+	p.group.mu.Lock()
+	defer p.group.mu.Unlock()
+	if _, err := p.group.w.Write(p.prefix); err != nil {
+		return err
+	}
+	_, err := p.group.w.Write(line)
+	return err
+}
@@ -0,0 +1,31 @@
+//go:build !windows
+
+package injector
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// setGroup makes the child the leader of a new process group, so signals
+// sent to the group also reach whatever it spawns.
+func setGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+func terminate(p *os.Process, group bool) error {
+	if group {
+		return syscall.Kill(-p.Pid, syscall.SIGTERM)
+	}
+	return p.Signal(syscall.SIGTERM)
+}
+
+func kill(p *os.Process, group bool) error {
+	if group {
+		if err := syscall.Kill(-p.Pid, syscall.SIGKILL); err == nil {
+			return nil
+		}
+	}
+	return p.Kill()
+}
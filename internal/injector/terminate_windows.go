@@ -0,0 +1,28 @@
+//go:build windows
+
+package injector
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+// setGroup is a no-op: Windows has no process groups to signal, so kill
+// takes down the child's process tree instead.
+func setGroup(cmd *exec.Cmd) {}
+
+// Windows has no SIGTERM; there is nothing gentler than Kill for a console
+// child we did not create in its own process group.
+func terminate(p *os.Process, group bool) error {
+	return kill(p, group)
+}
+
+func kill(p *os.Process, group bool) error {
+	if group {
+		if err := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(p.Pid)).Run(); err == nil {
+			return nil
+		}
+	}
+	return p.Kill()
+}
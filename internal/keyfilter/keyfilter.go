@@ -0,0 +1,44 @@
+package keyfilter
+
+import (
+	"path"
+	"strings"
+)
+
+// Split turns a comma-separated pattern list ("API_*,DB_*") into patterns.
+func Split(s string) []string {
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// Match reports whether key matches any of the glob patterns. An empty
+// pattern list matches every key.
+func Match(patterns []string, key string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, p := range patterns {
+		if ok, err := path.Match(p, key); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+func Apply(secrets map[string]string, patterns []string) map[string]string {
+	if len(patterns) == 0 {
+		return secrets
+	}
+	out := make(map[string]string)
+	for k, v := range secrets {
+		if Match(patterns, k) {
+			out[k] = v
+		}
+	}
+	return out
+}
@@ -0,0 +1,109 @@
+package postgres
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/SrPlugin/GhostEnv/internal/config"
+)
+
+const DefaultHost = "localhost"
+
+type Credentials struct {
+	Host     string
+	Port     int
+	Database string
+	User     string
+	Password string
+	SSLMode  string
+}
+
+// FromConfig reads the user and password from the vault keys named in
+// microservices.postgres.
+func FromConfig(cfg config.PostgresConfig, secrets map[string]string) (Credentials, error) {
+	if cfg.UserKey == "" || cfg.PassKey == "" {
+		return Credentials{}, fmt.Errorf("microservices.postgres.user_key and pass_key must be set")
+	}
+	user, ok := secrets[cfg.UserKey]
+	if !ok {
+		return Credentials{}, fmt.Errorf("vault has no %s (microservices.postgres.user_key)", cfg.UserKey)
+	}
+	pass, ok := secrets[cfg.PassKey]
+	if !ok {
+		return Credentials{}, fmt.Errorf("vault has no %s (microservices.postgres.pass_key)", cfg.PassKey)
+	}
+	c := Credentials{
+		Host:     cfg.Host,
+		Port:     cfg.Port,
+		Database: cfg.Database,
+		User:     user,
+		Password: pass,
+		SSLMode:  cfg.SSLMode,
+	}
+	if c.Host == "" {
+		c.Host = DefaultHost
+	}
+	return c, nil
+}
+
+func (c Credentials) url(password *url.Userinfo) string {
+	u := url.URL{
+		Scheme: "postgres",
+		User:   password,
+		Host:   c.Host,
+	}
+	if c.Port != 0 {
+		u.Host = net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+	}
+	if c.Database != "" {
+		u.Path = "/" + c.Database
+	}
+	if c.SSLMode != "" {
+		u.RawQuery = url.Values{"sslmode": {c.SSLMode}}.Encode()
+	}
+	return u.String()
+}
+
+func (c Credentials) URL() string {
+	return c.url(url.UserPassword(c.User, c.Password))
+}
+
+// MaskedURL is URL with the password replaced, safe to print or log.
+func (c Credentials) MaskedURL() string {
+	// The escaped user never contains a raw '@', so the first one ends userinfo.
+	return strings.Replace(c.url(url.User(c.User)), "@", ":****@", 1)
+}
+
+// Env returns the libpq PG* variables (https://www.postgresql.org/docs/current/libpq-envars.html).
+func (c Credentials) Env() map[string]string {
+	env := map[string]string{
+		"PGHOST":     c.Host,
+		"PGUSER":     c.User,
+		"PGPASSWORD": c.Password,
+	}
+	if c.Port != 0 {
+		env["PGPORT"] = strconv.Itoa(c.Port)
+	}
+	if c.Database != "" {
+		env["PGDATABASE"] = c.Database
+	}
+	if c.SSLMode != "" {
+		env["PGSSLMODE"] = c.SSLMode
+	}
+	return env
+}
+
+// Inject adds DATABASE_URL and the PG* variables to secrets without
+// overriding keys already stored in the vault.
+func (c Credentials) Inject(secrets map[string]string) {
+	vars := c.Env()
+	vars["DATABASE_URL"] = c.URL()
+	for k, v := range vars {
+		if _, exists := secrets[k]; !exists {
+			secrets[k] = v
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package procfile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+type Entry struct {
+	Name    string
+	Command string
+}
+
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func Parse(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, command, ok := strings.Cut(line, ":")
+		name = strings.TrimSpace(name)
+		command = strings.TrimSpace(command)
+		if !ok || !namePattern.MatchString(name) || command == "" {
+			return nil, fmt.Errorf("line %d: expected \"name: command\"", lineNo)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("line %d: duplicate process %q", lineNo, name)
+		}
+		seen[name] = true
+		entries = append(entries, Entry{Name: name, Command: command})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func ParseFile(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	entries, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return entries, nil
+}
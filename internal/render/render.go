@@ -0,0 +1,113 @@
+package render
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+var ErrInvalidSpec = fmt.Errorf("template spec must be in the form input:output")
+
+func funcMap() template.FuncMap {
+	return template.FuncMap{
+		"b64enc": func(s string) string {
+			return base64.StdEncoding.EncodeToString([]byte(s))
+		},
+		"json": func(v interface{}) (string, error) {
+			data, err := json.Marshal(v)
+			if err != nil {
+				return "", err
+			}
+			return string(data), nil
+		},
+		"default": func(def string, v interface{}) string {
+			s, _ := v.(string)
+			if s == "" {
+				return def
+			}
+			return s
+		},
+		"required": func(msg string, v interface{}) (string, error) {
+			s, _ := v.(string)
+			if s == "" {
+				return "", fmt.Errorf("%s", msg)
+			}
+			return s, nil
+		},
+	}
+}
+
+// Render executes src with secrets as the dot value. Referencing a key that
+// is not in secrets (e.g. {{ .MISSING }}) is an error; use
+// {{ index . "KEY" | default "x" }} for optional keys.
+func Render(name string, src []byte, secrets map[string]string) ([]byte, error) {
+	tmpl, err := template.New(name).
+		Option("missingkey=error").
+		Funcs(funcMap()).
+		Parse(string(src))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template %s: %w", name, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, secrets); err != nil {
+		return nil, fmt.Errorf("failed to render template %s: %w", name, err)
+	}
+	return buf.Bytes(), nil
+}
+
+func RenderFile(inputPath, outputPath string, secrets map[string]string) error {
+	src, err := os.ReadFile(inputPath)
+	if err != nil {
+		return fmt.Errorf("failed to read template: %w", err)
+	}
+	out, err := Render(filepath.Base(inputPath), src, secrets)
+	if err != nil {
+		return err
+	}
+	defer zeroBytes(out)
+	dir := filepath.Dir(outputPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	if err := os.WriteFile(outputPath, out, 0600); err != nil {
+		return fmt.Errorf("failed to write rendered file: %w", err)
+	}
+	return nil
+}
+
+type Spec struct {
+	Input  string
+	Output string
+}
+
+// ParseSpec splits "input:output", ignoring a Windows drive letter prefix.
+func ParseSpec(s string) (Spec, error) {
+	start := 0
+	if len(s) >= 2 && s[1] == ':' && isLetter(s[0]) {
+		start = 2
+	}
+	i := strings.Index(s[start:], ":")
+	if i < 0 {
+		return Spec{}, ErrInvalidSpec
+	}
+	in, out := s[:start+i], s[start+i+1:]
+	if in == "" || out == "" {
+		return Spec{}, ErrInvalidSpec
+	}
+	return Spec{Input: in, Output: out}, nil
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func zeroBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
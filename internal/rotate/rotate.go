@@ -0,0 +1,87 @@
+package rotate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/SrPlugin/GhostEnv/internal/config"
+	"github.com/SrPlugin/GhostEnv/internal/injector"
+)
+
+const (
+	DefaultLength  = 32
+	MinLength      = 16
+	DefaultTimeout = 60 * time.Second
+	TypeShell      = "shell"
+)
+
+// Request describes one rotation. Env holds extra variables for the rotator
+// (e.g. the current PG* credentials so it can connect before the change).
+type Request struct {
+	Key      string
+	OldValue string
+	NewValue string
+	Env      map[string]string
+}
+
+// Rotator applies a new secret value to the system that uses it. It must
+// return an error unless the new value is live; the vault is only updated
+// after Rotate succeeds.
+type Rotator interface {
+	Name() string
+	Rotate(ctx context.Context, req Request) error
+}
+
+func New(cfg config.RotatorConfig) (Rotator, error) {
+	switch cfg.Type {
+	case "", TypeShell:
+		if strings.TrimSpace(cfg.Command) == "" {
+			return nil, fmt.Errorf("shell rotator requires a command")
+		}
+		return &ShellRotator{Command: cfg.Command}, nil
+	}
+	return nil, fmt.Errorf("unknown rotator type %q", cfg.Type)
+}
+
+// ShellRotator runs a command through the platform shell. The values are
+// passed in GHOSTENV_ROTATE_KEY, GHOSTENV_OLD_VALUE and GHOSTENV_NEW_VALUE,
+// never on the command line, e.g.:
+//
+//	psql -c "ALTER ROLE $PGUSER PASSWORD '$GHOSTENV_NEW_VALUE'"
+type ShellRotator struct {
+	Command string
+}
+
+func (r *ShellRotator) Name() string {
+	return TypeShell
+}
+
+func (r *ShellRotator) Rotate(ctx context.Context, req Request) error {
+	name, args := injector.ShellCommand(r.Command)
+	cmd := exec.CommandContext(ctx, name, args...)
+	env := os.Environ()
+	for k, v := range req.Env {
+		env = append(env, k+"="+v)
+	}
+	env = append(env,
+		"GHOSTENV_ROTATE_KEY="+req.Key,
+		"GHOSTENV_OLD_VALUE="+req.OldValue,
+		"GHOSTENV_NEW_VALUE="+req.NewValue,
+	)
+	cmd.Env = env
+	var stderr bytes.Buffer
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket per client: perMinute requests refill
+// evenly, with bursts up to perMinute. A bucket idle for a minute is full
+// again, the same as a new one, so such buckets are swept once a minute.
+type rateLimiter struct {
+	mu        sync.Mutex
+	perMinute int
+	buckets   map[string]*bucket
+	swept     time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(perMinute int) *rateLimiter {
+	return &rateLimiter{perMinute: perMinute, buckets: make(map[string]*bucket)}
+}
+
+func (r *rateLimiter) allow(client string, now time.Time) bool {
+	if r.perMinute <= 0 {
+		return true
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if now.Sub(r.swept) >= time.Minute {
+		r.sweep(now)
+	}
+	b, ok := r.buckets[client]
+	if !ok {
+		b = &bucket{tokens: float64(r.perMinute), last: now}
+		r.buckets[client] = b
+	}
+	b.tokens += now.Sub(b.last).Minutes() * float64(r.perMinute)
+	if b.tokens > float64(r.perMinute) {
+		b.tokens = float64(r.perMinute)
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+func (r *rateLimiter) sweep(now time.Time) {
+	for client, b := range r.buckets {
+		if now.Sub(b.last) >= time.Minute {
+			delete(r.buckets, client)
+		}
+	}
+	r.swept = now
+}
@@ -0,0 +1,204 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/SrPlugin/GhostEnv/internal/audit"
+	"github.com/SrPlugin/GhostEnv/internal/keyfilter"
+)
+
+const DefaultRateLimit = 120
+
+// Vault is one unlocked environment served to clients.
+type Vault struct {
+	Path    string
+	Secrets map[string]string
+}
+
+type Options struct {
+	Addr      string
+	TLSConfig *tls.Config
+	Tokens    *TokenStore
+	Vaults    map[string]Vault
+	RateLimit int
+}
+
+type Server struct {
+	opts    Options
+	limiter *rateLimiter
+}
+
+func New(opts Options) *Server {
+	return &Server{opts: opts, limiter: newRateLimiter(opts.RateLimit)}
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", s.handleHealth)
+	mux.HandleFunc("GET /v1/secrets", s.handleBundle)
+	mux.HandleFunc("GET /v1/secrets/{key}", s.handleKey)
+	return mux
+}
+
+// ListenAndServe serves until ctx is cancelled, then shuts down gracefully.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	srv := &http.Server{
+		Addr:              s.opts.Addr,
+		Handler:           s.Handler(),
+		TLSConfig:         s.opts.TLSConfig,
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       10 * time.Second,
+		WriteTimeout:      10 * time.Second,
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		if s.opts.TLSConfig != nil {
+			errCh <- srv.ListenAndServeTLS("", "")
+		} else {
+			errCh <- srv.ListenAndServe()
+		}
+	}()
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			return err
+		}
+		if err := <-errCh; err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	}
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if !s.limiter.allow(clientIP(r), time.Now()) {
+		writeError(w, http.StatusTooManyRequests, "rate limit exceeded")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (s *Server) handleKey(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	tok, v, ok := s.authorize(w, r, key)
+	if !ok {
+		return
+	}
+	val, found := v.Secrets[key]
+	if !found || !tok.Allows(key) {
+		s.audit(w, r, v.Path, tok, key, "not found")
+		writeError(w, http.StatusNotFound, "secret not found")
+		return
+	}
+	if err := s.audit(w, r, v.Path, tok, key, ""); err != nil {
+		writeError(w, http.StatusServiceUnavailable, "audit log unavailable")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"key": key, "value": val})
+}
+
+func (s *Server) handleBundle(w http.ResponseWriter, r *http.Request) {
+	pattern := r.URL.Query().Get("pattern")
+	tok, v, ok := s.authorize(w, r, pattern)
+	if !ok {
+		return
+	}
+	query := keyfilter.Split(pattern)
+	out := make(map[string]string)
+	for k, val := range v.Secrets {
+		if tok.Allows(k) && keyfilter.Match(query, k) {
+			out[k] = val
+		}
+	}
+	if err := s.audit(w, r, v.Path, tok, pattern, ""); err != nil {
+		writeError(w, http.StatusServiceUnavailable, "audit log unavailable")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"environment": tok.Environment,
+		"secrets":     out,
+	})
+}
+
+// authorize checks the bearer token and rate limit and returns the vault for
+// the token's environment. Failures are written to the response and audited.
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request, key string) (Token, Vault, bool) {
+	client := clientIP(r)
+	value, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	tok, known := Token{}, false
+	if found && s.opts.Tokens != nil {
+		tok, known = s.opts.Tokens.Lookup(strings.TrimSpace(value))
+	}
+	if known {
+		client = "token:" + tok.Name
+	}
+	if !s.limiter.allow(client, time.Now()) {
+		s.audit(w, r, "", tok, key, "rate limit exceeded")
+		writeError(w, http.StatusTooManyRequests, "rate limit exceeded")
+		return Token{}, Vault{}, false
+	}
+	if !known {
+		s.audit(w, r, "", Token{}, key, "unauthorized")
+		w.Header().Set("WWW-Authenticate", `Bearer realm="ghostenv"`)
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return Token{}, Vault{}, false
+	}
+	v, ok := s.opts.Vaults[tok.Environment]
+	if !ok {
+		s.audit(w, r, "", tok, key, "environment not served")
+		writeError(w, http.StatusForbidden, "environment not served")
+		return Token{}, Vault{}, false
+	}
+	return tok, v, true
+}
+
+// audit records a request with the client (token name and address) and a
+// correlation ID, taken from X-Correlation-ID or X-Request-ID when the
+// client sends a safe one and echoed back. It fails only under
+// audit.fail_closed, in which case the secret must not be served.
+func (s *Server) audit(w http.ResponseWriter, r *http.Request, vaultPath string, tok Token, key, errMsg string) error {
+	id := audit.SanitizeCorrelationID(r.Header.Get("X-Correlation-ID"))
+	if id == "" {
+		id = audit.SanitizeCorrelationID(r.Header.Get("X-Request-ID"))
+	}
+	if id == "" {
+		id = audit.NewCorrelationID()
+	}
+	w.Header().Set("X-Correlation-ID", id)
+	client := clientIP(r)
+	if tok.Name != "" {
+		client = "token:" + tok.Name + "@" + client
+	}
+	ctx := audit.Context{Client: client, CorrelationID: id}
+	return ctx.Log(audit.ActionServe, vaultPath, tok.Environment, key, errMsg == "", errMsg)
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/SrPlugin/GhostEnv/internal/keyfilter"
+)
+
+const tokenPrefix = "gev_"
+
+// Token grants read access to keys matching Patterns in one environment.
+// Only the SHA-256 of the bearer value is stored.
+type Token struct {
+	Name        string    `json:"name"`
+	Hash        string    `json:"hash"`
+	Environment string    `json:"environment"`
+	Patterns    []string  `json:"patterns,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func (t Token) Allows(key string) bool {
+	return keyfilter.Match(t.Patterns, key)
+}
+
+type TokenStore struct {
+	path   string
+	Tokens []Token `json:"tokens"`
+}
+
+func LoadTokens(path string) (*TokenStore, error) {
+	s := &TokenStore{path: path}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("failed to read token file: %w", err)
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("failed to parse token file %s: %w", path, err)
+	}
+	return s, nil
+}
+
+func (s *TokenStore) Save() error {
+	sort.Slice(s.Tokens, func(i, j int) bool { return s.Tokens[i].Name < s.Tokens[j].Name })
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, data, 0600)
+}
+
+// Create adds a token and returns the bearer value, which is not stored.
+func (s *TokenStore) Create(name, environment string, patterns []string) (string, error) {
+	for _, t := range s.Tokens {
+		if t.Name == name {
+			return "", fmt.Errorf("token %q already exists", name)
+		}
+	}
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	value := tokenPrefix + base64.RawURLEncoding.EncodeToString(raw)
+	s.Tokens = append(s.Tokens, Token{
+		Name:        name,
+		Hash:        hashToken(value),
+		Environment: environment,
+		Patterns:    patterns,
+		CreatedAt:   time.Now().UTC(),
+	})
+	return value, nil
+}
+
+func (s *TokenStore) Revoke(name string) error {
+	for i, t := range s.Tokens {
+		if t.Name == name {
+			s.Tokens = append(s.Tokens[:i], s.Tokens[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("token %q not found", name)
+}
+
+func (s *TokenStore) Lookup(value string) (Token, bool) {
+	h := hashToken(value)
+	for _, t := range s.Tokens {
+		if t.Hash == h {
+			return t, true
+		}
+	}
+	return Token{}, false
+}
+
+func hashToken(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
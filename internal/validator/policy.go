@@ -0,0 +1,73 @@
+package validator
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ReservedNames are variables that change how the child process or its
+// loader behaves. Injecting them from a vault is almost always a mistake or
+// an attack, so they need an explicit override.
+var ReservedNames = []string{
+	"PATH", "HOME", "USER", "SHELL", "PWD", "IFS", "TMPDIR",
+	"LD_PRELOAD", "LD_LIBRARY_PATH", "LD_AUDIT",
+	"DYLD_INSERT_LIBRARIES", "DYLD_LIBRARY_PATH",
+	"BASH_ENV", "ENV", "PROMPT_COMMAND",
+	"GHOSTENV_PASS",
+}
+
+// Policy is the naming policy for one environment, on top of ValidateKey.
+type Policy struct {
+	// Pattern, when set, must match the whole key.
+	Pattern string
+	// Prefixes, when set, are the prefixes a key may start with.
+	Prefixes []string
+	// Reserved extends ReservedNames.
+	Reserved      []string
+	AllowReserved bool
+}
+
+func (p Policy) ValidateKey(key string) error {
+	if err := ValidateKey(key); err != nil {
+		return err
+	}
+	if !p.AllowReserved && p.reserved(key) {
+		return fmt.Errorf("%w: %s", ErrReservedKey, key)
+	}
+	if p.Pattern != "" {
+		re, err := regexp.Compile(`^(?:` + p.Pattern + `)$`)
+		if err != nil {
+			return fmt.Errorf("invalid key pattern: %w", err)
+		}
+		if !re.MatchString(key) {
+			return fmt.Errorf("%w: %s does not match %s", ErrKeyPolicy, key, p.Pattern)
+		}
+	}
+	if len(p.Prefixes) > 0 && !hasAnyPrefix(key, p.Prefixes) {
+		return fmt.Errorf("%w: %s must start with %s", ErrKeyPolicy, key, strings.Join(p.Prefixes, " or "))
+	}
+	return nil
+}
+
+// reserved compares without case, since Windows environment names are case
+// insensitive.
+func (p Policy) reserved(key string) bool {
+	for _, lists := range [][]string{ReservedNames, p.Reserved} {
+		for _, name := range lists {
+			if strings.EqualFold(key, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func hasAnyPrefix(key string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,150 @@
+package validator
+
+import (
+	"encoding/pem"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	TypeString = "string"
+	TypeURL    = "url"
+	TypeInt    = "int"
+	TypeBool   = "bool"
+	TypePort   = "port"
+	TypePEM    = "pem"
+	TypeRegex  = "regex"
+)
+
+// Types lists the value types a schema rule can declare.
+func Types() []string {
+	return []string{TypeString, TypeURL, TypeInt, TypeBool, TypePort, TypePEM, TypeRegex}
+}
+
+// Rule declares one key of an environment. Pattern, when set, must match the
+// whole value whatever the type; the regex type requires it.
+type Rule struct {
+	Type     string
+	Pattern  string
+	Optional bool
+}
+
+// Compile reports a rule that can never be satisfied: an unknown type, a
+// regex without a pattern or a pattern that does not compile.
+func (r Rule) Compile() error {
+	switch r.Type {
+	case "", TypeString, TypeURL, TypeInt, TypeBool, TypePort, TypePEM:
+	case TypeRegex:
+		if r.Pattern == "" {
+			return fmt.Errorf("type regex needs a pattern")
+		}
+	default:
+		return fmt.Errorf("unknown type %q (use %s)", r.Type, strings.Join(Types(), ", "))
+	}
+	if r.Pattern != "" {
+		if _, err := regexp.Compile(r.Pattern); err != nil {
+			return fmt.Errorf("invalid pattern: %w", err)
+		}
+	}
+	return nil
+}
+
+func (r Rule) check(v string) error {
+	if err := r.Compile(); err != nil {
+		return err
+	}
+	switch r.Type {
+	case TypeURL:
+		u, err := url.Parse(v)
+		if err != nil || u.Scheme == "" || (u.Host == "" && u.Opaque == "") {
+			return fmt.Errorf("not a URL with a scheme and host")
+		}
+	case TypeInt:
+		if _, err := strconv.ParseInt(v, 10, 64); err != nil {
+			return fmt.Errorf("not an integer")
+		}
+	case TypeBool:
+		if !isBool(v) {
+			return fmt.Errorf("not a boolean (true/false, 1/0, yes/no, on/off)")
+		}
+	case TypePort:
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 65535 {
+			return fmt.Errorf("not a port (1-65535)")
+		}
+	case TypePEM:
+		if !isPEM(v) {
+			return fmt.Errorf("not PEM data")
+		}
+	}
+	if r.Pattern != "" && !regexp.MustCompile(`^(?:`+r.Pattern+`)$`).MatchString(v) {
+		return fmt.Errorf("does not match pattern %s", r.Pattern)
+	}
+	return nil
+}
+
+func isBool(v string) bool {
+	if _, err := strconv.ParseBool(v); err == nil {
+		return true
+	}
+	switch strings.ToLower(v) {
+	case "yes", "no", "on", "off":
+		return true
+	}
+	return false
+}
+
+// isPEM accepts one or more PEM blocks with nothing but whitespace around them.
+func isPEM(v string) bool {
+	rest := []byte(v)
+	found := false
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		found = true
+	}
+	return found && strings.TrimSpace(string(rest)) == ""
+}
+
+// Problem is a key that fails its environment's schema. It never carries the
+// value.
+type Problem struct {
+	Key     string
+	Missing bool
+	Err     error
+}
+
+func (p Problem) String() string {
+	if p.Missing {
+		return p.Key + ": required but not set"
+	}
+	return fmt.Sprintf("%s: %v", p.Key, p.Err)
+}
+
+// Check validates secrets against rules, sorted by key. Required keys must be
+// present and non-empty; optional keys are only checked when they have a
+// value.
+func Check(secrets map[string]string, rules map[string]Rule) []Problem {
+	var problems []Problem
+	for key, rule := range rules {
+		v, ok := secrets[key]
+		if !ok || v == "" {
+			if !rule.Optional {
+				problems = append(problems, Problem{Key: key, Missing: true})
+			}
+			continue
+		}
+		if err := rule.check(v); err != nil {
+			problems = append(problems, Problem{Key: key, Err: err})
+		}
+	}
+	sort.Slice(problems, func(i, j int) bool { return problems[i].Key < problems[j].Key })
+	return problems
+}
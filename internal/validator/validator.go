@@ -6,20 +6,42 @@ import (
 )
 
 var (
-	ErrEmptyKey   = fmt.Errorf("key cannot be empty")
-	ErrInvalidKey = fmt.Errorf("key contains invalid characters")
+	ErrEmptyKey    = fmt.Errorf("key cannot be empty")
+	ErrInvalidKey  = fmt.Errorf("key contains invalid characters")
+	ErrReservedKey = fmt.Errorf("key is reserved")
+	ErrKeyPolicy   = fmt.Errorf("key violates the naming policy")
+	ErrNULValue    = fmt.Errorf("value contains a NUL byte")
 )
 
+// ValidateKey applies the POSIX portable rule for environment variable names:
+// a letter or underscore followed by letters, digits and underscores. Other
+// names (spaces, '=', NUL, newlines, a leading digit) break or are silently
+// dropped by shells and exec.
 func ValidateKey(key string) error {
 	if key == "" {
 		return ErrEmptyKey
 	}
-	if strings.Contains(key, "=") {
-		return ErrInvalidKey
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		switch {
+		case c == '_', c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z':
+		case c >= '0' && c <= '9':
+			if i == 0 {
+				return fmt.Errorf("%w: %q starts with a digit", ErrInvalidKey, key)
+			}
+		default:
+			return fmt.Errorf("%w: %q may only use letters, digits and underscores", ErrInvalidKey, key)
+		}
 	}
 	return nil
 }
 
-func ValidateValue(value string) error {
-	return nil
+// ValidateValue checks value against rule. Values containing NUL are always
+// rejected, since they cannot be passed through the environment; the zero
+// Rule accepts anything else.
+func ValidateValue(value string, rule Rule) error {
+	if strings.IndexByte(value, 0) >= 0 {
+		return ErrNULValue
+	}
+	return rule.check(value)
 }
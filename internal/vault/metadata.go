@@ -0,0 +1,119 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Metadata lives in the vault next to the secrets under keys with
+// metaPrefix. The ':' can never appear in a valid environment variable name,
+// so these entries cannot collide with secrets and are never injected.
+const metaPrefix = "__ghostenv:"
+
+const (
+	historyPrefix = "history:"
+	HistoryLimit  = 5
+)
+
+type Metadata map[string]string
+
+type HistoryEntry struct {
+	Value      string    `json:"value"`
+	ReplacedAt time.Time `json:"replaced_at"`
+}
+
+func IsMetadataKey(key string) bool {
+	return strings.HasPrefix(key, metaPrefix)
+}
+
+func splitMetadata(secrets map[string]string) Metadata {
+	meta := make(Metadata)
+	for k, v := range secrets {
+		if IsMetadataKey(k) {
+			meta[strings.TrimPrefix(k, metaPrefix)] = v
+			delete(secrets, k)
+		}
+	}
+	return meta
+}
+
+func joinMetadata(secrets map[string]string, meta Metadata) map[string]string {
+	if len(meta) == 0 {
+		return secrets
+	}
+	out := make(map[string]string, len(secrets)+len(meta))
+	for k, v := range secrets {
+		out[k] = v
+	}
+	for k, v := range meta {
+		out[metaPrefix+k] = v
+	}
+	return out
+}
+
+// History returns previous values of key, newest first.
+func (m Metadata) History(key string) []HistoryEntry {
+	var entries []HistoryEntry
+	if raw, ok := m[historyPrefix+key]; ok {
+		_ = json.Unmarshal([]byte(raw), &entries)
+	}
+	return entries
+}
+
+// PushHistory records value as the previous value of key, keeping at most
+// HistoryLimit entries.
+func (m Metadata) PushHistory(key, value string, at time.Time) {
+	entries := append([]HistoryEntry{{Value: value, ReplacedAt: at.UTC()}}, m.History(key)...)
+	if len(entries) > HistoryLimit {
+		entries = entries[:HistoryLimit]
+	}
+	data, _ := json.Marshal(entries)
+	m[historyPrefix+key] = string(data)
+}
+
+// Forget drops the history and encoding of key, for keys deleted by remove
+// or a prune so that no previous value outlives them.
+func (m Metadata) Forget(key string) {
+	delete(m, historyPrefix+key)
+	delete(m, encodingPrefix+key)
+}
+
+const (
+	encodingPrefix = "encoding:"
+	EncodingBase64 = "base64"
+)
+
+// Encoding returns how the stored value of key is encoded ("" for plain
+// text, EncodingBase64 for binary data).
+func (m Metadata) Encoding(key string) string {
+	return m[encodingPrefix+key]
+}
+
+func (m Metadata) SetEncoding(key, encoding string) {
+	if encoding == "" {
+		delete(m, encodingPrefix+key)
+		return
+	}
+	m[encodingPrefix+key] = encoding
+}
+
+// EncodeValue returns raw as a storable string. Text is kept as is; data that
+// is not valid UTF-8 or contains NUL bytes (which cannot be passed in an
+// environment variable) is base64-encoded and reported as EncodingBase64.
+func EncodeValue(raw []byte) (string, string) {
+	if utf8.Valid(raw) && bytes.IndexByte(raw, 0) < 0 {
+		return string(raw), ""
+	}
+	return base64.StdEncoding.EncodeToString(raw), EncodingBase64
+}
+
+func DecodeValue(value, encoding string) ([]byte, error) {
+	if encoding == EncodingBase64 {
+		return base64.StdEncoding.DecodeString(value)
+	}
+	return []byte(value), nil
+}
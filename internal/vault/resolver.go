@@ -1,6 +1,7 @@
 package vault
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 
@@ -20,40 +21,79 @@ const (
 
 type resolver struct {
 	projectRoot string
+	how         string
 	cfg         *config.Config
+	err         error
+	// dryRun skips creating the vault directories.
+	dryRun bool
 }
 
+// Resolution is a vault path together with the steps that led to it, as
+// shown by ghostenv where.
+type Resolution struct {
+	Path        string
+	Type        VaultType
+	Environment string
+	Steps       []string
+}
+
+// NewResolver loads the config for the project around the working directory.
+// If the config is invalid, config.Current falls back to the defaults and
+// ResolveVaultPath returns the config error.
 func NewResolver() Resolver {
-	root := findProjectRoot()
-	cfg, _ := config.Load(root)
+	return newResolver()
+}
+
+func newResolver() *resolver {
+	wd, _ := os.Getwd()
+	root, how := findProjectRoot()
+	cfg, err := config.Load(root)
+	if err == nil && root != wd && !cfg.Storage.IsRecursive() && cfg.ProjectDir() != wd {
+		how += "; ignored because storage.recursive_search is false" + from(cfg, "storage.recursive_search") + ", using the working directory"
+		root = wd
+		cfg, err = config.Load(root)
+	}
 	if cfg == nil {
 		cfg = config.Default()
 	}
+	if dir := cfg.ProjectDir(); dir != "" {
+		root = dir
+	}
 	config.SetCurrent(cfg)
 	config.SetProjectRoot(root)
-	return &resolver{projectRoot: root, cfg: cfg}
+	return &resolver{projectRoot: root, how: how, cfg: cfg, err: err}
 }
 
-func findProjectRoot() string {
+// FindProjectRoot returns the directory whose .ghostenv.yml or .ghostenv/
+// applies to the working directory, or the working directory itself.
+func FindProjectRoot() string {
+	root, _ := findProjectRoot()
+	return root
+}
+
+// findProjectRoot walks up from the working directory and also says what it
+// found.
+func findProjectRoot() (string, string) {
 	wd, err := os.Getwd()
 	if err != nil {
-		return ""
+		return "", "working directory unknown"
 	}
 
 	dir := wd
 	for {
-		// Project: .ghostenv/ directory
+		// Project: .ghostenv.yml config file
+		if _, err := os.Stat(filepath.Join(dir, config.ProjectConfigName)); err == nil {
+			return dir, "found " + config.ProjectConfigName + " in " + dir
+		}
+		// Project: .ghostenv/ directory, unless it belongs to a project of
+		// a multi-project file further up
 		vaultDir := filepath.Join(dir, config.ProjectVaultDir)
 		if info, err := os.Stat(vaultDir); err == nil && info.IsDir() {
-			return dir
+			return claimingRoot(dir)
 		}
 		vaultFile := filepath.Join(vaultDir, config.DefaultEnvironment+".gev")
 		if _, err := os.Stat(vaultFile); err == nil {
-			return dir
-		}
-		// Project: .ghostenv.yml config file
-		if _, err := os.Stat(filepath.Join(dir, config.ProjectConfigName)); err == nil {
-			return dir
+			return claimingRoot(dir)
 		}
 
 		parent := filepath.Dir(dir)
@@ -63,35 +103,99 @@ func findProjectRoot() string {
 		dir = parent
 	}
 
-	return wd
+	return wd, "no " + config.ProjectConfigName + " or " + config.ProjectVaultDir + "/ found, using the working directory"
 }
 
-func (r *resolver) ResolveVaultPath(environment string) (string, VaultType, error) {
-	if environment == "" && r.cfg != nil {
-		environment = r.cfg.Project.DefaultEnv
+// claimingRoot returns the directory of the nearest .ghostenv.yml above dir
+// if it is a multi-project file with a project in dir, else dir.
+func claimingRoot(dir string) (string, string) {
+	found := "found " + config.ProjectVaultDir + "/ in " + dir
+	for up := filepath.Dir(dir); ; up = filepath.Dir(up) {
+		if _, err := os.Stat(filepath.Join(up, config.ProjectConfigName)); err == nil {
+			if config.ClaimsDir(up, dir) {
+				return up, found + ", a project of " + filepath.Join(up, config.ProjectConfigName)
+			}
+			return dir, found
+		}
+		if filepath.Dir(up) == up {
+			return dir, found
+		}
 	}
-	if environment == "" {
-		environment = config.DefaultEnvironment
+}
+
+// from describes where a setting came from, for resolution steps.
+func from(cfg *config.Config, path string) string {
+	if o, ok := cfg.Source(path); ok {
+		return " (" + o.String() + ")"
 	}
+	return " (default)"
+}
 
-	home, err := os.UserHomeDir()
+func (r *resolver) ResolveVaultPath(environment string) (string, VaultType, error) {
+	res, err := r.resolve(environment)
 	if err != nil {
 		return "", "", err
 	}
+	return res.Path, res.Type, nil
+}
 
-	wd, err := os.Getwd()
+func (r *resolver) resolve(environment string) (*Resolution, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	res := &Resolution{}
+	step := func(format string, args ...any) {
+		res.Steps = append(res.Steps, fmt.Sprintf(format, args...))
+	}
+	switch {
+	case environment != "":
+		step("environment %s as requested", environment)
+	default:
+		environment = r.cfg.Project.DefaultEnv
+		if environment == "" {
+			environment = config.DefaultEnvironment
+		}
+		if _, ok := r.cfg.Source("project.default_env"); ok {
+			step("environment %s from project.default_env%s", environment, from(r.cfg, "project.default_env"))
+		} else {
+			step("environment %s (default)", environment)
+		}
+	}
+	res.Environment = environment
+
+	home, err := os.UserHomeDir()
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
 
 	// Global vault when project root is home (no project detected in a meaningful way)
 	absHome, _ := filepath.Abs(home)
 	absProject, _ := filepath.Abs(r.projectRoot)
-	if r.projectRoot == "" || absProject == absHome || r.projectRoot == wd {
-		if absProject == absHome {
-			vaultPath := filepath.Join(home, config.VaultFileName)
-			return vaultPath, VaultTypeGlobal, nil
+	if absProject == absHome {
+		step("project root is the home directory (%s), so the global vault is used", r.how)
+		res.Path, res.Type = filepath.Join(home, config.VaultFileName), VaultTypeGlobal
+		return res, nil
+	}
+	if by := r.cfg.SelectedBy(); by != "" {
+		step("project %s selected by %s, directory %s", r.cfg.Project.Name, by, r.projectRoot)
+	} else {
+		step("project root %s: %s", r.projectRoot, r.how)
+	}
+	res.Type = VaultTypeProject
+
+	// Explicit vault file from config.Storage.Environments
+	e := r.cfg.Storage.Environments[environment]
+	if e.Vault != "" {
+		p := e.Vault
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(r.projectRoot, p)
+		}
+		res.Path = filepath.Clean(p)
+		step("storage.environments.%s.vault %s%s", environment, e.Vault, from(r.cfg, "storage.environments."+environment+".vault"))
+		if err := r.mkdir(filepath.Dir(res.Path)); err != nil {
+			return nil, err
 		}
+		return res, nil
 	}
 
 	// Project vault: use config vault_dir (relative to project root) or default
@@ -99,24 +203,56 @@ func (r *resolver) ResolveVaultPath(environment string) (string, VaultType, erro
 	if vaultDir == "" {
 		vaultDir = filepath.Join(".", config.ProjectVaultDir)
 	}
+	step("vault_dir %s%s", vaultDir, from(r.cfg, "storage.vault_dir"))
 	if !filepath.IsAbs(vaultDir) {
 		vaultDir = filepath.Join(r.projectRoot, vaultDir)
 	}
-	if err := os.MkdirAll(vaultDir, 0755); err != nil {
-		return "", "", err
+	if err := r.mkdir(vaultDir); err != nil {
+		return nil, err
 	}
 	// Per-environment override from config.Storage.Environments
-	if r.cfg.Storage.Environments != nil {
-		if e, ok := r.cfg.Storage.Environments[environment]; ok && e.Dir != "" {
-			vaultDir = filepath.Join(vaultDir, e.Dir)
-			_ = os.MkdirAll(vaultDir, 0755)
-		}
+	if e.Dir != "" {
+		vaultDir = filepath.Join(vaultDir, e.Dir)
+		step("storage.environments.%s.dir %s%s", environment, e.Dir, from(r.cfg, "storage.environments."+environment+".dir"))
+		_ = r.mkdir(vaultDir)
 	}
-	vaultPath := filepath.Join(vaultDir, environment+".gev")
-	return vaultPath, VaultTypeProject, nil
+	step("file %s.gev in %s", environment, vaultDir)
+	res.Path = filepath.Join(vaultDir, environment+".gev")
+	return res, nil
+}
+
+func (r *resolver) mkdir(dir string) error {
+	if r.dryRun {
+		return nil
+	}
+	return os.MkdirAll(dir, 0755)
+}
+
+// Explain resolves the vault for environment like GetVaultPath and records
+// how, without creating any directories.
+func Explain(environment string) (*Resolution, error) {
+	r := newResolver()
+	r.dryRun = true
+	return r.resolve(environment)
 }
 
 func GetVaultPath(environment string) (string, VaultType, error) {
 	r := NewResolver()
 	return r.ResolveVaultPath(environment)
 }
+
+// SharedVaultPath returns the inherited shared vault for the current project,
+// or "" when microservices.inheritance is disabled.
+func SharedVaultPath() string {
+	cfg := config.Current()
+	if cfg == nil || !cfg.Microservices.Inheritance.Enabled || cfg.Microservices.Inheritance.SharedVault == "" {
+		return ""
+	}
+	p := cfg.Microservices.Inheritance.SharedVault
+	if !filepath.IsAbs(p) {
+		if root := config.ProjectRoot(); root != "" {
+			p = filepath.Join(root, p)
+		}
+	}
+	return filepath.Clean(p)
+}
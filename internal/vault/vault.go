@@ -1,6 +1,8 @@
 package vault
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 
@@ -12,18 +14,28 @@ type Service interface {
 	Load(password []byte) (map[string]string, error)
 	Save(secrets map[string]string, password []byte) error
 	Exists() bool
+	Metadata() Metadata
+	Fingerprint() (string, error)
 }
 
 type service struct {
 	vaultPath string
+	meta      Metadata
 }
 
 func NewService(vaultPath string) Service {
 	return &service{
 		vaultPath: vaultPath,
+		meta:      make(Metadata),
 	}
 }
 
+// Metadata returns the vault metadata read by the last Load. Changes are
+// written by the next Save.
+func (s *service) Metadata() Metadata {
+	return s.meta
+}
+
 func zeroBytes(b []byte) {
 	for i := range b {
 		b[i] = 0
@@ -48,16 +60,32 @@ func (s *service) Load(password []byte) (map[string]string, error) {
 		return nil, fmt.Errorf("failed to unmarshal vault data: %w", err)
 	}
 
+	s.meta = splitMetadata(secrets)
 	return secrets, nil
 }
 
 func (s *service) Save(secrets map[string]string, password []byte) error {
-	payload, err := json.Marshal(secrets)
+	payload, err := json.Marshal(joinMetadata(secrets, s.meta))
 	if err != nil {
 		return fmt.Errorf("failed to marshal secrets: %w", err)
 	}
 
-	encrypted, err := cipher.Encrypt(payload, password)
+	var encrypted []byte
+	if len(password) == 0 {
+		// Unlocked through the key cache: keep the vault's salt so the cached
+		// key stays valid.
+		data, loadErr := storage.LoadVault(s.vaultPath)
+		if loadErr != nil {
+			return fmt.Errorf("encryption failed: %w", cipher.ErrPasswordRequired)
+		}
+		salt, saltErr := cipher.Salt(data)
+		if saltErr != nil {
+			return fmt.Errorf("encryption failed: %w", saltErr)
+		}
+		encrypted, err = cipher.EncryptWithSalt(payload, nil, salt)
+	} else {
+		encrypted, err = cipher.Encrypt(payload, password)
+	}
 	if err != nil {
 		return fmt.Errorf("encryption failed: %w", err)
 	}
@@ -72,3 +100,14 @@ func (s *service) Save(secrets map[string]string, password []byte) error {
 func (s *service) Exists() bool {
 	return storage.VaultExists(s.vaultPath)
 }
+
+// Fingerprint identifies the current vault file contents without revealing
+// them: a SHA-256 of the encrypted file, which changes on every save.
+func (s *service) Fingerprint() (string, error) {
+	data, err := storage.LoadVault(s.vaultPath)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return "SHA256:" + hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,106 @@
+package watch
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultInterval = 500 * time.Millisecond
+	DefaultDebounce = 300 * time.Millisecond
+)
+
+type fileState struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+// Watcher polls a set of files and emits one event per burst of changes.
+// Polling keeps it dependency-free and portable; vault files are small and
+// rewritten atomically, so mtime and size are enough to spot a save.
+type Watcher struct {
+	paths    []string
+	interval time.Duration
+	debounce time.Duration
+	events   chan struct{}
+	done     chan struct{}
+	once     sync.Once
+}
+
+func New(paths []string, interval, debounce time.Duration) *Watcher {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	if debounce < 0 {
+		debounce = DefaultDebounce
+	}
+	w := &Watcher{
+		paths:    paths,
+		interval: interval,
+		debounce: debounce,
+		events:   make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+	go w.loop()
+	return w
+}
+
+func (w *Watcher) Events() <-chan struct{} {
+	return w.events
+}
+
+func (w *Watcher) Close() {
+	w.once.Do(func() { close(w.done) })
+}
+
+func (w *Watcher) loop() {
+	last := w.snapshot()
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	var pending <-chan time.Time
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			cur := w.snapshot()
+			if !equal(last, cur) {
+				last = cur
+				pending = time.After(w.debounce)
+			}
+		case <-pending:
+			pending = nil
+			select {
+			case w.events <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+func (w *Watcher) snapshot() []fileState {
+	out := make([]fileState, len(w.paths))
+	for i, p := range w.paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			continue
+		}
+		out[i] = fileState{exists: true, size: info.Size(), modTime: info.ModTime()}
+	}
+	return out
+}
+
+func equal(a, b []fileState) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].exists != b[i].exists || a[i].size != b[i].size || !a[i].modTime.Equal(b[i].modTime) {
+			return false
+		}
+	}
+	return true
+}